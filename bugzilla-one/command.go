@@ -0,0 +1,293 @@
+// Package bugzillaone implements the bugzilla-one command, which
+// imports individual bugs.
+package bugzillaone
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/openshift-metal3/jira-sync/pkg/audit"
+	"github.com/openshift-metal3/jira-sync/pkg/bugzilla"
+	"github.com/openshift-metal3/jira-sync/pkg/bugzillaissue"
+	"github.com/openshift-metal3/jira-sync/pkg/index"
+	"github.com/openshift-metal3/jira-sync/pkg/jirabackend"
+	"github.com/openshift-metal3/jira-sync/pkg/render"
+	"github.com/openshift-metal3/jira-sync/pkg/settings"
+	"github.com/openshift-metal3/jira-sync/pkg/target"
+	"github.com/openshift-metal3/jira-sync/pkg/watchers"
+)
+
+type syncArgs struct {
+	bugzillaClient *bugzilla.Client
+	bugzillaIDs    []string
+	importer       *bugzillaissue.Importer
+}
+
+// bugResult records what happened to one of the bugs given as input,
+// for the summary at the end of the run.
+type bugResult struct {
+	input  string
+	bugID  int
+	status string
+	keys   []string
+	err    error
+}
+
+func (r *bugResult) failed() bool {
+	return r.err != nil
+}
+
+func processAllIssues(args syncArgs) ([]*bugResult, error) {
+
+	// Fetch all of the bugs at once, then match them up with the
+	// inputs so that we can report on the ones that were not found.
+	bugs, err := args.bugzillaClient.GetMany(args.bugzillaIDs, bugzillaissue.ImportFields)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []*bugResult{}
+	processed := make(map[int]*bugResult)
+	for _, bugID := range args.bugzillaIDs {
+		result := &bugResult{input: bugID}
+		results = append(results, result)
+
+		bug := findBug(bugs, bugID)
+		if bug == nil {
+			result.status = "NOT FOUND"
+			result.err = fmt.Errorf("bug %s does not exist or is not visible", bugID)
+			continue
+		}
+		result.bugID = bug.ID
+
+		if previous, ok := processed[bug.ID]; ok {
+			// The same bug was given more than once, possibly by
+			// ID and alias.
+			result.status = previous.status
+			result.keys = previous.keys
+			result.err = previous.err
+			continue
+		}
+		processed[bug.ID] = result
+
+		if err := processOneIssue(args, *bug, result); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			result.status = "FAILED"
+			result.err = err
+		}
+	}
+
+	return results, nil
+}
+
+// findBug returns the bug matching the ID or alias, or nil.
+func findBug(bugs []bugzilla.Bug, bugID string) *bugzilla.Bug {
+	for i, bug := range bugs {
+		if strconv.Itoa(bug.ID) == bugID {
+			return &bugs[i]
+		}
+		for _, alias := range bug.Alias {
+			if strings.EqualFold(alias, bugID) {
+				return &bugs[i]
+			}
+		}
+	}
+	return nil
+}
+
+func showResults(results []*bugResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "\nINPUT\tBUG\tRESULT\tJIRA\n")
+	for _, result := range results {
+		bugID := "-"
+		if result.bugID != 0 {
+			bugID = strconv.Itoa(result.bugID)
+		}
+		keys := "-"
+		if len(result.keys) != 0 {
+			keys = strings.Join(result.keys, ",")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", result.input, bugID, result.status, keys)
+	}
+	w.Flush()
+}
+
+func processOneIssue(args syncArgs, bug bugzilla.Bug, result *bugResult) error {
+	keys, created, err := args.importer.Import(bug)
+	if err != nil {
+		return err
+	}
+	result.keys = keys
+	if created {
+		result.status = "CREATED"
+	} else {
+		result.status = "EXISTING"
+	}
+	return nil
+}
+
+// Run runs the command with the arguments following its name and
+// returns the exit code.
+func Run(cmdArgs []string) int {
+	flags := settings.NewFlagSet("bugzilla-one", "[bug...]",
+		"Import the bugs with the given IDs, aliases, or URLs, or report the\n"+
+			"existing jira tickets for them.")
+	server := settings.NewFlags(flags)
+	server.AddJira(flags)
+	server.AddAudit(flags)
+	server.AddBugzilla(flags)
+	jiraProject := flags.String("jira-project", "", "the jira project")
+	jiraComponent := flags.String("jira-component", "", "the jira component for new tickets")
+	jiraIssueType := flags.String("jira-issue-type", "bug", "the jira issue type for new tickets")
+	jiraEpic := flags.String("jira-epic", "", "the key of the epic to link new tickets to")
+	jiraParent := flags.String("jira-parent", "", "the key of the parent for new sub-tasks")
+	jiraLabels := flags.String("jira-labels", "", "comma separated extra labels for new tickets")
+	jiraAssignee := flags.String("jira-assignee", "", "the user to assign new tickets to")
+	jiraPriority := flags.String("jira-priority", "", "the priority of new tickets")
+	jiraFieldsFile := flags.String("jira-fields", "", "YAML file with values for other fields of new tickets, such as custom fields")
+	inputFile := flags.String("f", "", "read bug IDs, aliases, or URLs from a file (- for standard input)")
+	watchersFile := flags.String("watchers", "", "YAML file listing the watchers to add to new tickets")
+	keepWatching := flags.Bool("keep-watching", false, "leave the jira user running the import watching new tickets")
+	templatesFile := flags.String("templates", "", "YAML file with templates for the summary and description of new tickets")
+	dryRun := flags.Bool("dry-run", false, "print the changes to jira instead of making them")
+
+	if err := flags.Parse(cmdArgs); err != nil {
+		return settings.ParseExit(err)
+	}
+
+	if err := server.Resolve(nil); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	bugzillaClient, err := server.BugzillaClient(false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	// Sub-tasks take their component from the parent, so only
+	// require one for top level tickets.
+	if *jiraProject == "" || (*jiraComponent == "" && *jiraParent == "") {
+		fmt.Fprintf(os.Stderr, "Please specify the -jira-project and -jira-component\n")
+		return 1
+	}
+
+	refs, err := collectBugRefs(flags.Args(), *inputFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	if len(refs) == 0 {
+		fmt.Fprintf(os.Stderr, "Please specify the bugzilla IDs, aliases, or URLs as arguments or with -f\n")
+		return 1
+	}
+	bugIDs := []string{}
+	for _, ref := range refs {
+		bugID, err := parseBugRef(ref)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			return 1
+		}
+		bugIDs = append(bugIDs, bugID)
+	}
+
+	jiraBackend, err := server.JiraBackend()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	auditLog, err := audit.Open(server.Audit.Log, "bugzilla-one")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	defer auditLog.Close()
+	jiraBackend = auditLog.Wrap(jiraBackend)
+	var plan *jirabackend.DryRun
+	if *dryRun {
+		plan = jirabackend.NewDryRun(jiraBackend)
+		jiraBackend = plan
+	}
+	defer plan.ShowPlan()
+	jiraClient := jiraBackend.Client()
+	var jiraFields map[string]string
+	if *jiraFieldsFile != "" {
+		jiraFields, err = target.LoadFields(*jiraFieldsFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not load fields: %v\n", err)
+			return 1
+		}
+	}
+	jiraCreateMeta, err := target.FetchMeta(jiraClient, *jiraProject)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid jira settings: %v\n", err)
+		return 1
+	}
+	jiraTarget, err := target.Resolve(jiraCreateMeta, target.Options{
+		Project:   *jiraProject,
+		IssueType: *jiraIssueType,
+		Component: *jiraComponent,
+		Epic:      *jiraEpic,
+		Parent:    *jiraParent,
+		Labels:    target.ParseLabels(*jiraLabels),
+		Assignee:  *jiraAssignee,
+		Priority:  *jiraPriority,
+		Fields:    jiraFields,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid jira settings: %v\n", err)
+		return 1
+	}
+	if err := jiraTarget.CheckReferences(jiraBackend); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid jira settings: %v\n", err)
+		return 1
+	}
+
+	policy, err := watchers.New(*watchersFile, *keepWatching)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not load watchers: %v\n", err)
+		return 1
+	}
+
+	var templates *render.Templates
+	if *templatesFile != "" {
+		templates, err = render.Load(*templatesFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not load templates: %v\n", err)
+			return 1
+		}
+	}
+
+	args := syncArgs{
+		bugzillaClient: bugzillaClient,
+		bugzillaIDs:    bugIDs,
+		importer: &bugzillaissue.Importer{
+			JiraURL:        server.Jira.URL,
+			Jira:           jiraBackend,
+			BugzillaClient: bugzillaClient,
+			Target:         jiraTarget,
+			Index:          index.New(jiraBackend),
+			Watchers:       policy,
+			Templates:      templates,
+		},
+	}
+
+	results, err := processAllIssues(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+
+	showResults(results)
+
+	for _, result := range results {
+		if result.failed() {
+			return settings.ExitFailed
+		}
+	}
+	return 0
+}
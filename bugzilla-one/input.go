@@ -0,0 +1,83 @@
+package bugzillaone
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// parseBugRef turns one command line argument into a bug ID or alias
+// that bugzilla will accept. Arguments may be bare IDs, aliases (such
+// as CVE IDs), or show_bug.cgi URLs.
+func parseBugRef(ref string) (string, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return "", fmt.Errorf("empty bug reference")
+	}
+
+	if strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://") {
+		parsedURL, err := url.Parse(ref)
+		if err != nil {
+			return "", fmt.Errorf("could not parse bug URL %q: %s", ref, err)
+		}
+		id := parsedURL.Query().Get("id")
+		if id == "" {
+			return "", fmt.Errorf("no bug id found in URL %q", ref)
+		}
+		return id, nil
+	}
+
+	if strings.ContainsAny(ref, " \t/?&") {
+		return "", fmt.Errorf("%q is not a bug ID, alias, or URL", ref)
+	}
+	return ref, nil
+}
+
+// readBugRefs reads whitespace separated bug references from the
+// reader, skipping blank lines and lines starting with #.
+func readBugRefs(r io.Reader) ([]string, error) {
+	results := []string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		results = append(results, strings.Fields(line)...)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// collectBugRefs combines the command line arguments with the
+// contents of the input file, if one is given. The filename "-"
+// means standard input.
+func collectBugRefs(args []string, inputFile string) ([]string, error) {
+	refs := append([]string{}, args...)
+
+	if inputFile != "" {
+		var r io.Reader
+		if inputFile == "-" {
+			r = os.Stdin
+		} else {
+			f, err := os.Open(inputFile)
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+			r = f
+		}
+		fromFile, err := readBugRefs(r)
+		if err != nil {
+			return nil, fmt.Errorf("could not read bug list from %s: %s", inputFile, err)
+		}
+		refs = append(refs, fromFile...)
+	}
+
+	return refs, nil
+}
@@ -0,0 +1,182 @@
+// Package bugzillatojira implements the bugzilla command, which
+// imports the bugs found by a bugzilla search.
+package bugzillatojira
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/openshift-metal3/jira-sync/pkg/audit"
+	"github.com/openshift-metal3/jira-sync/pkg/bugzilla"
+	"github.com/openshift-metal3/jira-sync/pkg/bugzillaissue"
+	"github.com/openshift-metal3/jira-sync/pkg/index"
+	"github.com/openshift-metal3/jira-sync/pkg/jirabackend"
+	"github.com/openshift-metal3/jira-sync/pkg/render"
+	"github.com/openshift-metal3/jira-sync/pkg/settings"
+	"github.com/openshift-metal3/jira-sync/pkg/target"
+	"github.com/openshift-metal3/jira-sync/pkg/watchers"
+)
+
+// Run runs the command with the arguments following its name and
+// returns the exit code.
+func Run(cmdArgs []string) int {
+	flags := settings.NewFlagSet("bugzilla", "",
+		"Import the open bugs of a bugzilla product, or the bugs found by a\n"+
+			"search, creating a jira ticket for each new one.")
+	server := settings.NewFlags(flags)
+	server.AddJira(flags)
+	server.AddAudit(flags)
+	server.AddBugzilla(flags)
+	bugzillaProduct := flags.String("bugzilla-product", "", "the product name for the bugzilla query")
+	bugzillaComponent := flags.String("bugzilla-component", "", "the component name for the bugzilla query")
+	bugzillaQuery := flags.String("bugzilla-query", "", "a buglist.cgi URL to use instead of the product query")
+	bugzillaSavedSearch := flags.String("bugzilla-saved-search", "", "the name of a saved search to use instead of the product query")
+	bugzillaSharerID := flags.String("bugzilla-sharer-id", "", "the user ID of the owner of a shared saved search")
+	jiraProject := flags.String("jira-project", "", "the jira project")
+	jiraComponent := flags.String("jira-component", "", "the jira component for new tickets")
+	jiraPriority := flags.String("jira-priority", "", "the priority of new tickets")
+	jiraFieldsFile := flags.String("jira-fields", "", "YAML file with values for other fields of new tickets, such as custom fields")
+	statusSync := flags.Bool("sync-status", false, "move existing tickets through the jira workflow to match the bug status")
+	statusMapFile := flags.String("status-map", "", "YAML file mapping bugzilla status to jira transitions (with -sync-status)")
+	watchersFile := flags.String("watchers", "", "YAML file listing the watchers to add to new tickets")
+	keepWatching := flags.Bool("keep-watching", false, "leave the jira user running the import watching new tickets")
+	templatesFile := flags.String("templates", "", "YAML file with templates for the summary and description of new tickets")
+	dryRun := flags.Bool("dry-run", false, "print the changes to jira instead of making them")
+
+	if err := flags.Parse(cmdArgs); err != nil {
+		return settings.ParseExit(err)
+	}
+
+	if err := server.Resolve(nil); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	bugzillaClient, err := server.BugzillaClient(false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	var query url.Values
+	switch {
+	case *bugzillaQuery != "" && *bugzillaSavedSearch != "":
+		fmt.Fprintf(os.Stderr, "Please specify only one of -bugzilla-query and -bugzilla-saved-search\n")
+		return 1
+	case *bugzillaQuery != "":
+		query, err = bugzillaClient.QueryFromBuglistURL(*bugzillaQuery)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not use -bugzilla-query: %v\n", err)
+			return 1
+		}
+	case *bugzillaSavedSearch != "":
+		query = bugzilla.SavedSearchQuery(*bugzillaSavedSearch, *bugzillaSharerID)
+	case *bugzillaProduct == "":
+		fmt.Fprintf(os.Stderr, "Please provide a product to filter the bugzilla query (-bugzilla-product), a -bugzilla-query, or a -bugzilla-saved-search\n")
+		return 1
+	default:
+		query = bugzillaissue.ProductQuery(*bugzillaProduct, *bugzillaComponent)
+	}
+
+	if *jiraProject == "" || *jiraComponent == "" {
+		fmt.Fprintf(os.Stderr, "Please specify the -jira-project and -jira-component\n")
+		return 1
+	}
+
+	var bzStatusMap bugzillaissue.StatusMap
+	if *statusSync {
+		if *statusMapFile == "" {
+			fmt.Fprintf(os.Stderr, "Please specify the -status-map to use with -sync-status\n")
+			return 1
+		}
+		bzStatusMap, err = bugzillaissue.LoadStatusMap(*statusMapFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not load status map: %v\n", err)
+			return 1
+		}
+	}
+
+	jiraBackend, err := server.JiraBackend()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	auditLog, err := audit.Open(server.Audit.Log, "bugzilla")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	defer auditLog.Close()
+	jiraBackend = auditLog.Wrap(jiraBackend)
+	var plan *jirabackend.DryRun
+	if *dryRun {
+		plan = jirabackend.NewDryRun(jiraBackend)
+		jiraBackend = plan
+	}
+	defer plan.ShowPlan()
+	jiraClient := jiraBackend.Client()
+	var jiraFields map[string]string
+	if *jiraFieldsFile != "" {
+		jiraFields, err = target.LoadFields(*jiraFieldsFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not load fields: %v\n", err)
+			return 1
+		}
+	}
+	jiraCreateMeta, err := target.FetchMeta(jiraClient, *jiraProject)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid jira settings: %v\n", err)
+		return 1
+	}
+	jiraTarget, err := target.Resolve(jiraCreateMeta, target.Options{
+		Project:   *jiraProject,
+		IssueType: "bug",
+		Component: *jiraComponent,
+		Priority:  *jiraPriority,
+		Fields:    jiraFields,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid jira settings: %v\n", err)
+		return 1
+	}
+
+	policy, err := watchers.New(*watchersFile, *keepWatching)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not load watchers: %v\n", err)
+		return 1
+	}
+
+	var templates *render.Templates
+	if *templatesFile != "" {
+		templates, err = render.Load(*templatesFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not load templates: %v\n", err)
+			return 1
+		}
+	}
+
+	importer := &bugzillaissue.Importer{
+		JiraURL:        server.Jira.URL,
+		Jira:           jiraBackend,
+		BugzillaClient: bugzillaClient,
+		Target:         jiraTarget,
+		Index:          index.New(jiraBackend),
+		StatusMap:      bzStatusMap,
+		Watchers:       policy,
+		Templates:      templates,
+	}
+
+	// A query given by the user replaces the product search
+	// entirely, including the list of statuses.
+	err = importer.ImportQuery(query)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	importer.Counts.Failures.Show()
+	if importer.Counts.Failures.Len() != 0 {
+		return settings.ExitFailed
+	}
+	return 0
+}
@@ -0,0 +1,267 @@
+// Package findclosed implements the find-closed command, which marks
+// jira tickets whose upstream tickets have been closed.
+package findclosed
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/google/go-github/github"
+
+	"github.com/openshift-metal3/jira-sync/pkg/audit"
+	"github.com/openshift-metal3/jira-sync/pkg/bugzilla"
+	"github.com/openshift-metal3/jira-sync/pkg/bugzillaissue"
+	"github.com/openshift-metal3/jira-sync/pkg/githubissue"
+	"github.com/openshift-metal3/jira-sync/pkg/jirabackend"
+	"github.com/openshift-metal3/jira-sync/pkg/render"
+	"github.com/openshift-metal3/jira-sync/pkg/settings"
+	"github.com/openshift-metal3/jira-sync/pkg/stats"
+)
+
+type syncArgs struct {
+	bugzillaClient *bugzilla.Client
+	githubClient   *github.Client
+	jiraURL        string
+	jira           jirabackend.Backend
+	jiraProject    string
+	templates      *render.Templates
+}
+
+// reportClosedIssues comments on the open tickets whose upstream
+// tickets are closed. Tickets that cannot be checked are added to the
+// failures, and only an error searching jira is returned.
+func reportClosedIssues(args syncArgs, failures *stats.Failures) error {
+
+	search := fmt.Sprintf("status != CLOSED and status != DONE and status != OBSOLETE and ( labels = github or labels = bugzilla ) and project = %s", args.jiraProject)
+
+	opts := jira.SearchOptions{
+		StartAt:    0,
+		MaxResults: 50,
+	}
+
+	for {
+		jiraIssues, err := args.jira.Search(search, &opts)
+		if err != nil {
+			return fmt.Errorf("Failed to search for issue: %v", err)
+		}
+
+		if len(jiraIssues) == 0 {
+			break
+		}
+
+		for _, jiraIssue := range jiraIssues {
+			url := fmt.Sprintf("%s/browse/%s", args.jiraURL, jiraIssue.Key)
+			fmt.Printf("%s", url)
+			if err := reportClosedIssue(args, jiraIssue); err != nil {
+				fmt.Fprintf(os.Stderr, "\nERROR: %v\n", err)
+				failures.Add(url, err)
+			}
+		}
+
+		opts.StartAt += len(jiraIssues)
+	}
+
+	return nil
+}
+
+// Look for github:org:repo:issue or bugzilla:issue within the HREF
+// syntax for Jira ([title|url]). The URL is the UI version so we never
+// care about that. The type (github or bugzilla) is extracted
+// separately so we can switch handling based on it.
+var linkSearch = regexp.MustCompile("\\[(github|bugzilla):(.+?)\\|.+\\]")
+
+func reportClosedIssue(args syncArgs, jiraIssue jira.Issue) error {
+	ctx := context.Background()
+
+	isClosed := false
+	var item *render.Item
+
+	match := linkSearch.FindStringSubmatch(jiraIssue.Fields.Description)
+	if len(match) == 0 {
+		fmt.Printf("\tunlinked?\n")
+		return nil
+	}
+
+	switch match[1] {
+
+	case "github":
+		fields := strings.Split(match[2], ":")
+		if len(fields) != 3 {
+			return fmt.Errorf("Could not parse github slug %q", match[2])
+		}
+		fmt.Printf("\tgithub org = %q repo = %q issue = %q",
+			fields[0], fields[1], fields[2])
+
+		issueNum, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return err
+		}
+		ghIssue, _, err := args.githubClient.Issues.Get(ctx, fields[0], fields[1], issueNum)
+		if err != nil {
+			return err
+		}
+
+		isClosed = (*ghIssue.State == "closed")
+		item = githubissue.Item(fields[0], fields[1], ghIssue)
+
+	case "bugzilla":
+		fmt.Printf("\tbz = %s", match[2])
+		bug, err := args.bugzillaClient.Get(match[2], bugzillaissue.ImportFields)
+		switch {
+		case bugzilla.IsNotFound(err):
+			return fmt.Errorf("bug %s does not exist: %s", match[2], err)
+		case bugzilla.IsAuth(err):
+			return fmt.Errorf("not allowed to see bug %s: %s", match[2], err)
+		case err != nil:
+			return err
+		}
+
+		isClosed = bug.Status == "CLOSED"
+		item = bugzillaissue.Item(args.bugzillaClient, *bug)
+
+	default:
+		return fmt.Errorf("Could not parse %q", match[0])
+	}
+
+	if !isClosed {
+		fmt.Printf("\n")
+		return nil
+	}
+
+	fmt.Printf(" CLOSED")
+
+	message, err := args.templates.ClosedComment(item)
+	if err != nil {
+		return err
+	}
+
+	needToAdd := true
+
+	// The search results do not include comments, so we have to
+	// fetch tickets when we need the comments.
+	commentedIssue, err := args.jira.GetIssue(jiraIssue.Key, nil)
+	if err != nil {
+		return fmt.Errorf("fetching issue %s: %s", jiraIssue.Key, err)
+	}
+
+	if commentedIssue.Fields.Comments != nil {
+		for _, comment := range commentedIssue.Fields.Comments.Comments {
+			if strings.TrimSpace(comment.Body) == strings.TrimSpace(message) {
+				needToAdd = false
+				break
+			}
+		}
+	} else {
+		fmt.Printf(" nil comments")
+	}
+
+	if needToAdd {
+		_, err := args.jira.AddComment(jiraIssue.ID, message)
+		if err != nil {
+			return fmt.Errorf("adding comment: %s", err)
+		}
+		fmt.Printf(" UPDATED")
+	}
+
+	fmt.Printf("\n")
+	return nil
+}
+
+// Run runs the command with the arguments following its name and
+// returns the exit code.
+func Run(cmdArgs []string) int {
+	flags := settings.NewFlagSet("find-closed", "",
+		"Find the open jira tickets imported from github issues or bugs that\n"+
+			"have been closed upstream, and comment on them.")
+	server := settings.NewFlags(flags)
+	server.AddJira(flags)
+	server.AddAudit(flags)
+	server.AddGithub(flags)
+	server.AddBugzilla(flags)
+	jiraProject := flags.String("jira-project", "", "the jira project")
+	templatesFile := flags.String("templates", "", "YAML file with the template for the comment on closed tickets")
+	dryRun := flags.Bool("dry-run", false, "print the changes to jira instead of making them")
+
+	if err := flags.Parse(cmdArgs); err != nil {
+		return settings.ParseExit(err)
+	}
+
+	if err := server.Resolve(nil); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	// Without any credentials we can only see public bugs, but that
+	// is how this command has always run so continue to allow it.
+	bugzillaClient, err := server.BugzillaClient(true)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	if *jiraProject == "" {
+		fmt.Fprintf(os.Stderr, "Please specify the -jira-project\n")
+		return 1
+	}
+
+	var templates *render.Templates
+	if *templatesFile != "" {
+		templates, err = render.Load(*templatesFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not load templates: %v\n", err)
+			return 1
+		}
+	}
+
+	jiraBackend, err := server.JiraBackend()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	auditLog, err := audit.Open(server.Audit.Log, "find-closed")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	defer auditLog.Close()
+	jiraBackend = auditLog.Wrap(jiraBackend)
+	var plan *jirabackend.DryRun
+	if *dryRun {
+		plan = jirabackend.NewDryRun(jiraBackend)
+		jiraBackend = plan
+	}
+	defer plan.ShowPlan()
+
+	tc, err := server.GithubHTTPClient()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	githubClient := github.NewClient(tc)
+
+	args := syncArgs{
+		bugzillaClient: bugzillaClient,
+		githubClient:   githubClient,
+		jiraURL:        server.Jira.URL,
+		jira:           jiraBackend,
+		jiraProject:    *jiraProject,
+		templates:      templates,
+	}
+
+	failures := &stats.Failures{}
+	err = reportClosedIssues(args, failures)
+	failures.Show()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	if failures.Len() != 0 {
+		return settings.ExitFailed
+	}
+	return 0
+}
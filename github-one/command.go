@@ -0,0 +1,289 @@
+// Package githubone implements the github-one command, which imports
+// individual github issues.
+package githubone
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/github"
+
+	"github.com/openshift-metal3/jira-sync/pkg/audit"
+	"github.com/openshift-metal3/jira-sync/pkg/githubissue"
+	"github.com/openshift-metal3/jira-sync/pkg/index"
+	"github.com/openshift-metal3/jira-sync/pkg/jirabackend"
+	"github.com/openshift-metal3/jira-sync/pkg/render"
+	"github.com/openshift-metal3/jira-sync/pkg/routing"
+	"github.com/openshift-metal3/jira-sync/pkg/settings"
+	"github.com/openshift-metal3/jira-sync/pkg/stats"
+	"github.com/openshift-metal3/jira-sync/pkg/target"
+	"github.com/openshift-metal3/jira-sync/pkg/usermap"
+	"github.com/openshift-metal3/jira-sync/pkg/versionmap"
+	"github.com/openshift-metal3/jira-sync/pkg/watchers"
+)
+
+var issueURLPattern = regexp.MustCompile("^https://github.com/([^/]+)/([^/]+)/issues/(\\d+)/?$")
+
+type syncArgs struct {
+	githubClient *github.Client
+	issueURLs    []string
+	importer     *githubissue.Importer
+}
+
+// parseIssueURL returns the org, repository, and issue number from
+// the URL of a github issue.
+func parseIssueURL(url string) (org, repo string, number int, err error) {
+	match := issueURLPattern.FindStringSubmatch(url)
+	if match == nil {
+		err = fmt.Errorf("%q is not a github issue URL", url)
+		return
+	}
+	org = match[1]
+	repo = match[2]
+	number, err = strconv.Atoi(match[3])
+	if err != nil {
+		err = fmt.Errorf("could not convert issue number %q to integer: %s", match[3], err)
+	}
+	return
+}
+
+func processOneIssue(args syncArgs, url string) error {
+	org, repo, number, err := parseIssueURL(url)
+	if err != nil {
+		return err
+	}
+
+	ghIssue, _, err := args.githubClient.Issues.Get(context.Background(), org, repo, number)
+	if err != nil {
+		return fmt.Errorf("Could not get issue %s: %s", url, err)
+	}
+	if ghIssue.PullRequestLinks != nil {
+		return fmt.Errorf("%s is a pull request, not an issue", url)
+	}
+
+	_, _, err = args.importer.Import(org, repo, ghIssue)
+	return err
+}
+
+func processAllIssues(args syncArgs) *stats.Failures {
+	failures := &stats.Failures{}
+	for _, url := range args.issueURLs {
+		if err := processOneIssue(args, url); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			failures.Add(url, err)
+		}
+	}
+	return failures
+}
+
+// Run runs the command with the arguments following its name and
+// returns the exit code.
+func Run(cmdArgs []string) int {
+	flags := settings.NewFlagSet("github-one", "issue-url...",
+		"Import the github issues with the given URLs, or report the existing\n"+
+			"jira tickets for them.")
+	server := settings.NewFlags(flags)
+	server.AddJira(flags)
+	server.AddAudit(flags)
+	server.AddGithub(flags)
+	jiraProject := flags.String("jira-project", "", "the jira project")
+	jiraComponent := flags.String("jira-component", "", "the jira component for new tickets")
+	jiraIssueType := flags.String("jira-issue-type", "story", "the jira issue type for new tickets")
+	jiraEpic := flags.String("jira-epic", "", "the key of the epic to link new tickets to")
+	jiraParent := flags.String("jira-parent", "", "the key of the parent for new sub-tasks")
+	jiraLabels := flags.String("jira-labels", "", "comma separated extra labels for new tickets")
+	jiraAssignee := flags.String("jira-assignee", "", "the user to assign new tickets to")
+	jiraPriority := flags.String("jira-priority", "", "the priority of new tickets")
+	jiraFieldsFile := flags.String("jira-fields", "", "YAML file with values for other fields of new tickets, such as custom fields")
+	routingFile := flags.String("routing-rules", "", "YAML file with rules choosing the epic or parent of new tickets")
+	userMapFile := flags.String("user-map", "", "YAML file mapping github logins to jira users")
+	milestoneMapFile := flags.String("milestone-map", "", "YAML file mapping github milestones to jira fix versions")
+	createVersions := flags.Bool("create-versions", false, "create fix versions from the milestone map that are missing in the project")
+	update := flags.Bool("update", false, "update existing tickets to match the github issues")
+	watchersFile := flags.String("watchers", "", "YAML file listing the watchers to add to new tickets")
+	keepWatching := flags.Bool("keep-watching", false, "leave the jira user running the import watching new tickets")
+	templatesFile := flags.String("templates", "", "YAML file with templates for the summary and description of new tickets")
+	dryRun := flags.Bool("dry-run", false, "print the changes to jira instead of making them")
+
+	if err := flags.Parse(cmdArgs); err != nil {
+		return settings.ParseExit(err)
+	}
+
+	if err := server.Resolve(nil); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	if err := server.RequireGithub(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	// Sub-tasks take their component from the parent, so only
+	// require one for top level tickets.
+	if *jiraProject == "" || (*jiraComponent == "" && *jiraParent == "") {
+		fmt.Fprintf(os.Stderr, "Please specify the -jira-project and -jira-component\n")
+		return 1
+	}
+
+	if len(flags.Args()) == 0 {
+		fmt.Fprintf(os.Stderr, "Please specify the github issue URLs as arguments\n")
+		return 1
+	}
+	for _, url := range flags.Args() {
+		if _, _, _, err := parseIssueURL(url); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			return 1
+		}
+	}
+
+	jiraBackend, err := server.JiraBackend()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	auditLog, err := audit.Open(server.Audit.Log, "github-one")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	defer auditLog.Close()
+	jiraBackend = auditLog.Wrap(jiraBackend)
+	var plan *jirabackend.DryRun
+	if *dryRun {
+		plan = jirabackend.NewDryRun(jiraBackend)
+		jiraBackend = plan
+	}
+	defer plan.ShowPlan()
+	jiraClient := jiraBackend.Client()
+	var jiraFields map[string]string
+	if *jiraFieldsFile != "" {
+		jiraFields, err = target.LoadFields(*jiraFieldsFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not load fields: %v\n", err)
+			return 1
+		}
+	}
+	jiraCreateMeta, err := target.FetchMeta(jiraClient, *jiraProject)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid jira settings: %v\n", err)
+		return 1
+	}
+	jiraTarget, err := target.Resolve(jiraCreateMeta, target.Options{
+		Project:   *jiraProject,
+		IssueType: *jiraIssueType,
+		Component: *jiraComponent,
+		Epic:      *jiraEpic,
+		Parent:    *jiraParent,
+		Labels:    target.ParseLabels(*jiraLabels),
+		Assignee:  *jiraAssignee,
+		Priority:  *jiraPriority,
+		Fields:    jiraFields,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid jira settings: %v\n", err)
+		return 1
+	}
+	if err := jiraTarget.CheckReferences(jiraBackend); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid jira settings: %v\n", err)
+		return 1
+	}
+
+	var routes *routing.Rules
+	if *routingFile != "" {
+		routes, err = routing.Load(*routingFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not load routing rules: %v\n", err)
+			return 1
+		}
+		if err := routes.Check(jiraTarget, jiraClient); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid routing rules: %v\n", err)
+			return 1
+		}
+	}
+
+	var users *usermap.Map
+	if *userMapFile != "" {
+		users, err = usermap.Load(*userMapFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not load user map: %v\n", err)
+			return 1
+		}
+	}
+
+	policy, err := watchers.New(*watchersFile, *keepWatching)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not load watchers: %v\n", err)
+		return 1
+	}
+
+	var templates *render.Templates
+	if *templatesFile != "" {
+		templates, err = render.Load(*templatesFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not load templates: %v\n", err)
+			return 1
+		}
+	}
+
+	var versions *versionmap.Map
+	if *milestoneMapFile != "" {
+		if !jiraTarget.AllowsFixVersions() {
+			fmt.Fprintf(os.Stderr, "Invalid jira settings: issue type %s in %s does not have fix versions\n",
+				jiraTarget.IssueTypeName, *jiraProject)
+			return 1
+		}
+		versions, err = versionmap.Load(*milestoneMapFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not load milestone map: %v\n", err)
+			return 1
+		}
+		if err := versions.Connect(jiraBackend, *jiraProject, *createVersions); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid jira settings: %v\n", err)
+			return 1
+		}
+	}
+
+	tc, err := server.GithubHTTPClient()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	githubClient := github.NewClient(tc)
+
+	args := syncArgs{
+		githubClient: githubClient,
+		issueURLs:    flags.Args(),
+		importer: &githubissue.Importer{
+			JiraURL:   server.Jira.URL,
+			Jira:      jiraBackend,
+			Target:    jiraTarget,
+			Index:     index.New(jiraBackend),
+			Routes:    routes,
+			Users:     users,
+			Versions:  versions,
+			Update:    *update,
+			Watchers:  policy,
+			Templates: templates,
+		},
+	}
+
+	failures := processAllIssues(args)
+
+	if unmapped := users.Unmapped(); len(unmapped) != 0 {
+		fmt.Printf("\nUnmapped github users: %s\n", strings.Join(unmapped, ", "))
+	}
+	if unmapped := versions.Unmapped(); len(unmapped) != 0 {
+		fmt.Printf("\nUnmapped github milestones: %s\n", strings.Join(unmapped, ", "))
+	}
+
+	failures.Show()
+	if failures.Len() != 0 {
+		return settings.ExitFailed
+	}
+	return 0
+}
@@ -0,0 +1,222 @@
+// Package githubtojira implements the github command, which imports
+// the open issues of a github org.
+package githubtojira
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/go-github/github"
+
+	"github.com/openshift-metal3/jira-sync/pkg/audit"
+	"github.com/openshift-metal3/jira-sync/pkg/githubissue"
+	"github.com/openshift-metal3/jira-sync/pkg/index"
+	"github.com/openshift-metal3/jira-sync/pkg/jirabackend"
+	"github.com/openshift-metal3/jira-sync/pkg/render"
+	"github.com/openshift-metal3/jira-sync/pkg/routing"
+	"github.com/openshift-metal3/jira-sync/pkg/settings"
+	"github.com/openshift-metal3/jira-sync/pkg/target"
+	"github.com/openshift-metal3/jira-sync/pkg/usermap"
+	"github.com/openshift-metal3/jira-sync/pkg/versionmap"
+	"github.com/openshift-metal3/jira-sync/pkg/watchers"
+)
+
+// Run runs the command with the arguments following its name and
+// returns the exit code.
+func Run(cmdArgs []string) int {
+	flags := settings.NewFlagSet("github", "[repo...]",
+		"Import the open issues from the repositories of a github org, or only\n"+
+			"the named repositories, creating a jira ticket for each new one.")
+	server := settings.NewFlags(flags)
+	server.AddJira(flags)
+	server.AddAudit(flags)
+	server.AddGithub(flags)
+	githubOrg := flags.String("github-org", "", "the organization to scan")
+	githubLabel := flags.String("github-label", "", "the issue label for filtering")
+	githubIgnore := flags.String("github-ignore", "", "comma separated names of repos to ignore")
+	jiraProject := flags.String("jira-project", "", "the jira project")
+	jiraComponent := flags.String("jira-component", "", "the jira component for new tickets")
+	jiraPriority := flags.String("jira-priority", "", "the priority of new tickets")
+	jiraFieldsFile := flags.String("jira-fields", "", "YAML file with values for other fields of new tickets, such as custom fields")
+	routingFile := flags.String("routing-rules", "", "YAML file with rules choosing the epic or parent of new tickets")
+	userMapFile := flags.String("user-map", "", "YAML file mapping github logins to jira users")
+	milestoneMapFile := flags.String("milestone-map", "", "YAML file mapping github milestones to jira fix versions")
+	createVersions := flags.Bool("create-versions", false, "create fix versions from the milestone map that are missing in the project")
+	update := flags.Bool("update", false, "update existing tickets to match the github issues")
+	watchersFile := flags.String("watchers", "", "YAML file listing the watchers to add to new tickets")
+	keepWatching := flags.Bool("keep-watching", false, "leave the jira user running the import watching new tickets")
+	templatesFile := flags.String("templates", "", "YAML file with templates for the summary and description of new tickets")
+	dryRun := flags.Bool("dry-run", false, "print the changes to jira instead of making them")
+
+	if err := flags.Parse(cmdArgs); err != nil {
+		return settings.ParseExit(err)
+	}
+
+	if err := server.Resolve(nil); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	if err := server.RequireGithub(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	if *githubOrg == "" {
+		fmt.Fprintf(os.Stderr, "Please specify the -github-org\n")
+		return 1
+	}
+
+	if *jiraProject == "" || *jiraComponent == "" {
+		fmt.Fprintf(os.Stderr, "Please specify the -jira-project and -jira-component\n")
+		return 1
+	}
+
+	jiraBackend, err := server.JiraBackend()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	auditLog, err := audit.Open(server.Audit.Log, "github")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	defer auditLog.Close()
+	jiraBackend = auditLog.Wrap(jiraBackend)
+	var plan *jirabackend.DryRun
+	if *dryRun {
+		plan = jirabackend.NewDryRun(jiraBackend)
+		jiraBackend = plan
+	}
+	defer plan.ShowPlan()
+	jiraClient := jiraBackend.Client()
+	var jiraFields map[string]string
+	if *jiraFieldsFile != "" {
+		jiraFields, err = target.LoadFields(*jiraFieldsFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not load fields: %v\n", err)
+			return 1
+		}
+	}
+	jiraCreateMeta, err := target.FetchMeta(jiraClient, *jiraProject)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid jira settings: %v\n", err)
+		return 1
+	}
+	jiraTarget, err := target.Resolve(jiraCreateMeta, target.Options{
+		Project:   *jiraProject,
+		IssueType: "story",
+		Component: *jiraComponent,
+		Priority:  *jiraPriority,
+		Fields:    jiraFields,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid jira settings: %v\n", err)
+		return 1
+	}
+
+	var routes *routing.Rules
+	if *routingFile != "" {
+		routes, err = routing.Load(*routingFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not load routing rules: %v\n", err)
+			return 1
+		}
+		if err := routes.Check(jiraTarget, jiraClient); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid routing rules: %v\n", err)
+			return 1
+		}
+	}
+
+	var users *usermap.Map
+	if *userMapFile != "" {
+		users, err = usermap.Load(*userMapFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not load user map: %v\n", err)
+			return 1
+		}
+	}
+
+	var versions *versionmap.Map
+	if *milestoneMapFile != "" {
+		if !jiraTarget.AllowsFixVersions() {
+			fmt.Fprintf(os.Stderr, "Invalid jira settings: issue type %s in %s does not have fix versions\n",
+				jiraTarget.IssueTypeName, *jiraProject)
+			return 1
+		}
+		versions, err = versionmap.Load(*milestoneMapFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not load milestone map: %v\n", err)
+			return 1
+		}
+		if err := versions.Connect(jiraBackend, *jiraProject, *createVersions); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid jira settings: %v\n", err)
+			return 1
+		}
+	}
+
+	policy, err := watchers.New(*watchersFile, *keepWatching)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not load watchers: %v\n", err)
+		return 1
+	}
+
+	var templates *render.Templates
+	if *templatesFile != "" {
+		templates, err = render.Load(*templatesFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not load templates: %v\n", err)
+			return 1
+		}
+	}
+
+	tc, err := server.GithubHTTPClient()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	githubClient := github.NewClient(tc)
+
+	importer := &githubissue.Importer{
+		JiraURL:   server.Jira.URL,
+		Jira:      jiraBackend,
+		Target:    jiraTarget,
+		Index:     index.New(jiraBackend),
+		Routes:    routes,
+		Users:     users,
+		Versions:  versions,
+		Update:    *update,
+		Watchers:  policy,
+		Templates: templates,
+	}
+
+	err = importer.ImportSource(githubClient, githubissue.Source{
+		Org:    *githubOrg,
+		Repos:  flags.Args(),
+		Label:  *githubLabel,
+		Ignore: strings.Split(*githubIgnore, ","),
+	})
+	showUnmapped(users, versions)
+	importer.Counts.Failures.Show()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	if importer.Counts.Failures.Len() != 0 {
+		return settings.ExitFailed
+	}
+	return 0
+}
+
+// showUnmapped lists the github users and milestones seen during the
+// run that are not in the maps, so the maps can be updated.
+func showUnmapped(users *usermap.Map, versions *versionmap.Map) {
+	if unmapped := users.Unmapped(); len(unmapped) != 0 {
+		fmt.Printf("\nUnmapped github users: %s\n", strings.Join(unmapped, ", "))
+	}
+	if unmapped := versions.Unmapped(); len(unmapped) != 0 {
+		fmt.Printf("\nUnmapped github milestones: %s\n", strings.Join(unmapped, ", "))
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/openshift-metal3/jira-sync/pkg/audit"
+	"github.com/openshift-metal3/jira-sync/pkg/settings"
+)
+
+func auditCommand(cmdArgs []string) int {
+	flags := settings.NewFlagSet("audit", "",
+		"Show the changes recorded in the audit log, optionally only those\n"+
+			"of one run, for one jira ticket or upstream ticket, or in a range of\n"+
+			"dates.")
+	server := settings.NewFlags(flags)
+	server.AddAudit(flags)
+	run := flags.String("run", "", "only show changes made by this run")
+	key := flags.String("key", "", "only show changes to this jira ticket")
+	slug := flags.String("slug", "", "only show changes to tickets imported from this upstream ticket, such as bugzilla:1234")
+	since := flags.String("since", "", "only show changes on or after this date (YYYY-MM-DD) or time (RFC 3339)")
+	until := flags.String("until", "", "only show changes before this time, or on or before this date")
+	asJSON := flags.Bool("json", false, "print the records as JSON lines instead of a table")
+
+	if err := flags.Parse(cmdArgs); err != nil {
+		return settings.ParseExit(err)
+	}
+
+	if err := server.Resolve(nil); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	if server.Audit.Log == "" {
+		fmt.Fprintf(os.Stderr, "Please specify the -audit-log\n")
+		return 1
+	}
+
+	filter := audit.Filter{Run: *run, Key: *key, Slug: *slug}
+	var err error
+	if filter.Since, err = parseDate(*since, false); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -since: %v\n", err)
+		return 1
+	}
+	if filter.Until, err = parseDate(*until, true); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -until: %v\n", err)
+		return 1
+	}
+
+	records, err := audit.Read(server.Audit.Log, filter)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		for _, r := range records {
+			encoder.Encode(r)
+		}
+		return 0
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "TIME\tRUN\tCOMMAND\tJOB\tKEY\tSLUG\tACTION\tSUMMARY\n")
+	for _, r := range records {
+		summary := strings.Join(strings.Fields(r.Summary), " ")
+		if r.Error != "" {
+			summary = fmt.Sprintf("FAILED: %s (%s)", summary, strings.Join(strings.Fields(r.Error), " "))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			r.Time.Local().Format("2006-01-02 15:04:05"), dash(r.Run),
+			r.Command, dash(r.Job), dash(r.Key), dash(r.Slug), r.Action, summary)
+	}
+	w.Flush()
+	return 0
+}
+
+// parseDate parses a date in local time or an RFC 3339 time. A date
+// given as the end of a range includes the whole day.
+func parseDate(value string, end bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither YYYY-MM-DD nor an RFC 3339 time", value)
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+func dash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/openshift-metal3/jira-sync/pkg/settings"
+)
+
+// config describes a set of sync jobs, along with the servers they
+// talk to.
+type config struct {
+	settings.Settings `yaml:",inline"`
+
+	// Defaults fill in the target and options of every job that
+	// does not set them itself.
+	Defaults struct {
+		Target  targetConfig `yaml:"target"`
+		Options jobOptions   `yaml:"options"`
+	} `yaml:"defaults"`
+
+	Jobs []*job `yaml:"jobs"`
+}
+
+// job is one import, from either github or bugzilla.
+type job struct {
+	Name     string          `yaml:"name"`
+	Github   *githubSource   `yaml:"github"`
+	Bugzilla *bugzillaSource `yaml:"bugzilla"`
+	Target   targetConfig    `yaml:"target"`
+	Options  jobOptions      `yaml:"options"`
+}
+
+type githubSource struct {
+	Org    string   `yaml:"org"`
+	Repos  []string `yaml:"repos"`
+	Label  string   `yaml:"label"`
+	Ignore []string `yaml:"ignore"`
+}
+
+type bugzillaSource struct {
+	Product     string `yaml:"product"`
+	Component   string `yaml:"component"`
+	Query       string `yaml:"query"`
+	SavedSearch string `yaml:"saved-search"`
+	SharerID    string `yaml:"sharer-id"`
+}
+
+type targetConfig struct {
+	Project   string   `yaml:"project"`
+	Component string   `yaml:"component"`
+	IssueType string   `yaml:"issue-type"`
+	Epic      string   `yaml:"epic"`
+	Parent    string   `yaml:"parent"`
+	Labels    []string `yaml:"labels"`
+	Assignee  string   `yaml:"assignee"`
+	Priority  string   `yaml:"priority"`
+	// Fields maps field names to values, which may be templates.
+	Fields map[string]string `yaml:"fields"`
+}
+
+type jobOptions struct {
+	Update         bool   `yaml:"update"`
+	UserMap        string `yaml:"user-map"`
+	MilestoneMap   string `yaml:"milestone-map"`
+	CreateVersions bool   `yaml:"create-versions"`
+	RoutingRules   string `yaml:"routing-rules"`
+	SyncStatus     bool   `yaml:"sync-status"`
+	StatusMap      string `yaml:"status-map"`
+	Watchers       string `yaml:"watchers"`
+	KeepWatching   bool   `yaml:"keep-watching"`
+	Templates      string `yaml:"templates"`
+}
+
+// loadConfig reads and checks the config file. Relative paths to
+// other files are taken to be relative to the directory of the config
+// file.
+func loadConfig(filename string) (*config, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &config{}
+	if err := yaml.UnmarshalStrict(content, cfg); err != nil {
+		return nil, fmt.Errorf("Unable to parse config %s: %s", filename, settings.RedactYAMLError(err))
+	}
+
+	if len(cfg.Jobs) == 0 {
+		return nil, fmt.Errorf("No jobs found in %s", filename)
+	}
+
+	dir := filepath.Dir(filename)
+	cfg.Settings.ResolvePaths(dir)
+	names := make(map[string]bool)
+	for i, j := range cfg.Jobs {
+		if j.Name == "" {
+			return nil, fmt.Errorf("Job %d in %s has no name", i+1, filename)
+		}
+		if names[j.Name] {
+			return nil, fmt.Errorf("Job name %q is used more than once in %s", j.Name, filename)
+		}
+		names[j.Name] = true
+
+		j.applyDefaults(cfg.Defaults.Target, cfg.Defaults.Options)
+		j.Options.resolvePaths(dir)
+		if err := j.validate(); err != nil {
+			return nil, fmt.Errorf("Job %q in %s: %s", j.Name, filename, err)
+		}
+	}
+
+	return cfg, nil
+}
+
+func (j *job) applyDefaults(t targetConfig, opts jobOptions) {
+	setDefault(&j.Target.Project, t.Project)
+	setDefault(&j.Target.Component, t.Component)
+	setDefault(&j.Target.IssueType, t.IssueType)
+	setDefault(&j.Target.Epic, t.Epic)
+	setDefault(&j.Target.Parent, t.Parent)
+	setDefault(&j.Target.Assignee, t.Assignee)
+	setDefault(&j.Target.Priority, t.Priority)
+	if j.Target.Labels == nil {
+		j.Target.Labels = t.Labels
+	}
+	// The job's fields are added to the default ones, replacing
+	// any with the same name.
+	if len(t.Fields) != 0 {
+		fields := make(map[string]string)
+		for name, value := range t.Fields {
+			fields[name] = value
+		}
+		for name, value := range j.Target.Fields {
+			fields[name] = value
+		}
+		j.Target.Fields = fields
+	}
+
+	j.Options.KeepWatching = j.Options.KeepWatching || opts.KeepWatching
+	setDefault(&j.Options.Watchers, opts.Watchers)
+	setDefault(&j.Options.Templates, opts.Templates)
+
+	// Only take the default options that make sense for the source,
+	// so one set of defaults can serve both kinds of jobs.
+	if j.Github != nil {
+		j.Options.Update = j.Options.Update || opts.Update
+		j.Options.CreateVersions = j.Options.CreateVersions || opts.CreateVersions
+		setDefault(&j.Options.UserMap, opts.UserMap)
+		setDefault(&j.Options.MilestoneMap, opts.MilestoneMap)
+		setDefault(&j.Options.RoutingRules, opts.RoutingRules)
+	}
+	if j.Bugzilla != nil {
+		j.Options.SyncStatus = j.Options.SyncStatus || opts.SyncStatus
+		setDefault(&j.Options.StatusMap, opts.StatusMap)
+	}
+}
+
+// issueType returns the issue type for new tickets, which is the
+// same as for the single source commands unless the job says
+// otherwise.
+func (j *job) issueType() string {
+	switch {
+	case j.Target.IssueType != "":
+		return j.Target.IssueType
+	case j.Github != nil:
+		return "story"
+	default:
+		return "bug"
+	}
+}
+
+func setDefault(value *string, def string) {
+	if *value == "" {
+		*value = def
+	}
+}
+
+func (opts *jobOptions) resolvePaths(dir string) {
+	for _, path := range []*string{
+		&opts.UserMap, &opts.MilestoneMap, &opts.RoutingRules, &opts.StatusMap,
+		&opts.Watchers, &opts.Templates,
+	} {
+		if *path != "" && !filepath.IsAbs(*path) {
+			*path = filepath.Join(dir, *path)
+		}
+	}
+}
+
+func (j *job) validate() error {
+	if (j.Github == nil) == (j.Bugzilla == nil) {
+		return fmt.Errorf("needs exactly one of github or bugzilla as the source")
+	}
+
+	if j.Github != nil {
+		if j.Github.Org == "" {
+			return fmt.Errorf("needs the github org")
+		}
+		if j.Options.SyncStatus {
+			return fmt.Errorf("sync-status only applies to bugzilla jobs")
+		}
+	}
+
+	if j.Bugzilla != nil {
+		src := j.Bugzilla
+		if src.Query != "" && src.SavedSearch != "" {
+			return fmt.Errorf("needs only one of the bugzilla query and saved-search")
+		}
+		if src.Product == "" && src.Query == "" && src.SavedSearch == "" {
+			return fmt.Errorf("needs a bugzilla product, query, or saved-search")
+		}
+		if j.Options.Update || j.Options.UserMap != "" || j.Options.MilestoneMap != "" ||
+			j.Options.CreateVersions || j.Options.RoutingRules != "" {
+			return fmt.Errorf("update, user-map, milestone-map, create-versions, and routing-rules only apply to github jobs")
+		}
+		if j.Options.SyncStatus && j.Options.StatusMap == "" {
+			return fmt.Errorf("needs a status-map to use with sync-status")
+		}
+	}
+
+	// Sub-tasks take their component from the parent, so only
+	// require one for top level tickets.
+	if j.Target.Project == "" || (j.Target.Component == "" && j.Target.Parent == "") {
+		return fmt.Errorf("needs the target project and component")
+	}
+
+	return nil
+}
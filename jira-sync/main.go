@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	bugzillaone "github.com/openshift-metal3/jira-sync/bugzilla-one"
+	bugzillatojira "github.com/openshift-metal3/jira-sync/bugzilla-to-jira"
+	findclosed "github.com/openshift-metal3/jira-sync/find-closed"
+	githubone "github.com/openshift-metal3/jira-sync/github-one"
+	githubtojira "github.com/openshift-metal3/jira-sync/github-to-jira"
+	"github.com/openshift-metal3/jira-sync/pkg/settings"
+	prcheck "github.com/openshift-metal3/jira-sync/pr-check"
+)
+
+// command is one of the subcommands of jira-sync. It is given the
+// arguments following its name and returns the exit code.
+type command struct {
+	name        string
+	description string
+	run         func(args []string) int
+}
+
+var commands = []command{
+	{"github", "import the open issues of a github org", githubtojira.Run},
+	{"github-one", "import individual github issues", githubone.Run},
+	{"bugzilla", "import the bugs found by a bugzilla search", bugzillatojira.Run},
+	{"bugzilla-one", "import individual bugs", bugzillaone.Run},
+	{"find-closed", "comment on tickets closed upstream", findclosed.Run},
+	{"pr-check", "report on the pull requests linked from tickets", prcheck.Run},
+	{"run", "run the sync jobs in a config file", runCommand},
+	{"validate", "check the jira settings of sync jobs", validateCommand},
+	{"audit", "show the changes recorded in the audit log", auditCommand},
+	{"revert", "undo the changes of a run recorded in the audit log", revertCommand},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [options]\n\nCommands:\n", settings.Program)
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-14s %s\n", cmd.name, cmd.description)
+	}
+	fmt.Fprintf(os.Stderr, "\nUse \"%s <command> -h\" for the options of a command.\n", settings.Program)
+	fmt.Fprintf(os.Stderr, "\nThe exit code is 0 on success, 1 if the command could not run, and %d\n"+
+		"if it ran but some tickets or jobs failed.\n", settings.ExitFailed)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(1)
+	}
+
+	name := os.Args[1]
+	for _, cmd := range commands {
+		if cmd.name == name {
+			os.Exit(cmd.run(os.Args[2:]))
+		}
+	}
+
+	if name == "-h" || name == "-help" || name == "--help" || name == "help" {
+		usage()
+		os.Exit(0)
+	}
+	fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", name)
+	usage()
+	os.Exit(1)
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+
+	"github.com/openshift-metal3/jira-sync/pkg/audit"
+	"github.com/openshift-metal3/jira-sync/pkg/jirabackend"
+	"github.com/openshift-metal3/jira-sync/pkg/settings"
+	"github.com/openshift-metal3/jira-sync/pkg/target"
+	"github.com/openshift-metal3/jira-sync/pkg/usermap"
+)
+
+// reverter undoes the changes recorded for one run.
+type reverter struct {
+	backend    jirabackend.Backend
+	myself     *jira.User
+	tickets    string
+	transition string
+	component  string
+
+	deleted  map[string]bool
+	reverted int
+	skipped  int
+	failed   int
+}
+
+func revertCommand(cmdArgs []string) int {
+	flags := settings.NewFlagSet("revert", "<run ID>",
+		"Undo a run recorded in the audit log. The tickets the run created are\n"+
+			"closed, moved to another component, or deleted, and the comments it\n"+
+			"added are deleted, unless someone else has changed them since. The\n"+
+			"changes are only printed unless -apply is given.")
+	server := settings.NewFlags(flags)
+	server.AddJira(flags)
+	server.AddAudit(flags)
+	tickets := flags.String("tickets", "close", "what to do with the tickets the run created: close, move, delete, or keep")
+	transition := flags.String("transition", "Close", "the transition, or the status it leads to, for closing tickets")
+	component := flags.String("component", "", "the component to move tickets to (with -tickets move)")
+	apply := flags.Bool("apply", false, "make the changes instead of printing them")
+
+	if err := flags.Parse(cmdArgs); err != nil {
+		return settings.ParseExit(err)
+	}
+
+	if flags.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Please specify the run ID, as shown by the audit command\n")
+		return 1
+	}
+	runID := flags.Arg(0)
+
+	switch *tickets {
+	case "close", "delete", "keep":
+	case "move":
+		if *component == "" {
+			fmt.Fprintf(os.Stderr, "Please specify the -component to move tickets to\n")
+			return 1
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown -tickets %q, use close, move, delete, or keep\n", *tickets)
+		return 1
+	}
+
+	if err := server.Resolve(nil); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	if server.Audit.Log == "" {
+		fmt.Fprintf(os.Stderr, "Please specify the -audit-log\n")
+		return 1
+	}
+
+	records, err := audit.Read(server.Audit.Log, audit.Filter{Run: runID})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	if len(records) == 0 {
+		fmt.Fprintf(os.Stderr, "No changes are recorded for run %s in %s\n", runID, server.Audit.Log)
+		return 1
+	}
+
+	jiraBackend, err := server.JiraBackend()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	auditLog, err := audit.Open(server.Audit.Log, "revert")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	defer auditLog.Close()
+	jiraBackend = auditLog.Wrap(jiraBackend)
+	var plan *jirabackend.DryRun
+	if !*apply {
+		plan = jirabackend.NewDryRun(jiraBackend)
+		jiraBackend = plan
+	}
+
+	myself, err := jiraBackend.Myself()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not look up the jira user: %v\n", err)
+		return 1
+	}
+
+	r := &reverter{
+		backend:    jiraBackend,
+		myself:     myself,
+		tickets:    *tickets,
+		transition: *transition,
+		component:  *component,
+		deleted:    make(map[string]bool),
+	}
+
+	if r.tickets == "move" {
+		if err := r.checkComponent(records); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+	}
+
+	// Tickets first, so the comments on deleted tickets can be left
+	// alone.
+	seen := make(map[string]bool)
+	for _, record := range records {
+		if record.Action != "create" || record.Error != "" || record.Key == "" || seen[record.Key] {
+			continue
+		}
+		seen[record.Key] = true
+		if r.tickets != "keep" {
+			r.revertTicket(record)
+		}
+	}
+	for _, record := range records {
+		if record.Action != "comment" || record.Error != "" || record.Comment == "" {
+			continue
+		}
+		if !r.deleted[record.Key] {
+			r.revertComment(record)
+		}
+	}
+
+	fmt.Printf("\nRun %s: %d reverted, %d skipped, %d failed\n", runID, r.reverted, r.skipped, r.failed)
+	if plan != nil {
+		plan.ShowPlan()
+		fmt.Printf("\nUse -apply to make these changes.\n")
+	}
+	if r.failed != 0 {
+		return settings.ExitFailed
+	}
+	return 0
+}
+
+func (r *reverter) skip(key, format string, args ...interface{}) {
+	r.skipped++
+	fmt.Printf("%s SKIPPED %s\n", key, fmt.Sprintf(format, args...))
+}
+
+func (r *reverter) fail(key string, err error) {
+	r.failed++
+	fmt.Fprintf(os.Stderr, "%s ERROR %s\n", key, err)
+}
+
+// checkComponent makes sure the component tickets are moved to exists
+// in the projects of all of the tickets, before any are moved.
+func (r *reverter) checkComponent(records []*audit.Record) error {
+	checked := make(map[string]bool)
+	for _, record := range records {
+		dash := strings.LastIndex(record.Key, "-")
+		if record.Action != "create" || dash <= 0 {
+			continue
+		}
+		project := record.Key[:dash]
+		if checked[project] {
+			continue
+		}
+		checked[project] = true
+		meta, err := target.FetchMeta(r.backend.Client(), project)
+		if err != nil {
+			return err
+		}
+		r.component, err = target.CheckComponent(meta, project, r.component)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// changedBy returns the name of someone other than the user who made
+// the run who has changed the ticket or commented on it, if there is
+// anyone.
+func (r *reverter) changedBy(issue *jira.Issue, importer *jira.User) string {
+	if issue.Changelog != nil {
+		for _, history := range issue.Changelog.Histories {
+			if !usermap.SameUser(&history.Author, importer) {
+				return usermap.UserName(&history.Author)
+			}
+		}
+	}
+	if issue.Fields != nil && issue.Fields.Comments != nil {
+		for _, comment := range issue.Fields.Comments.Comments {
+			if !usermap.SameUser(&comment.Author, importer) {
+				return usermap.UserName(&comment.Author)
+			}
+		}
+	}
+	return ""
+}
+
+func (r *reverter) revertTicket(record *audit.Record) {
+	issue, err := r.backend.GetIssue(record.Key, &jira.GetQueryOptions{
+		Fields: "status,comment",
+		Expand: "changelog",
+	})
+	if err != nil {
+		r.fail(record.Key, err)
+		return
+	}
+	// Records from before the user was recorded were made by the user
+	// reverting them, as far as we can tell.
+	importer := r.myself
+	if record.User != "" {
+		importer = usermap.ParseUser(record.User)
+	}
+	if by := r.changedBy(issue, importer); by != "" {
+		r.skip(record.Key, "changed by %s", by)
+		return
+	}
+
+	switch r.tickets {
+	case "delete":
+		if err := r.backend.DeleteIssue(issue.ID); err != nil {
+			r.fail(record.Key, err)
+			return
+		}
+		r.deleted[record.Key] = true
+		fmt.Printf("%s DELETED\n", record.Key)
+
+	case "close":
+		transitions, _, err := r.backend.Client().Issue.GetTransitions(issue.Key)
+		if err != nil {
+			r.fail(record.Key, err)
+			return
+		}
+		var transition *jira.Transition
+		for i, t := range transitions {
+			if strings.EqualFold(t.Name, r.transition) || strings.EqualFold(t.To.Name, r.transition) {
+				transition = &transitions[i]
+				break
+			}
+		}
+		if transition == nil {
+			status := ""
+			if issue.Fields != nil && issue.Fields.Status != nil {
+				status = issue.Fields.Status.Name
+			}
+			r.skip(record.Key, "%q is not allowed from %q", r.transition, status)
+			return
+		}
+		if err := r.backend.DoTransition(issue.ID, transition.ID); err != nil {
+			r.fail(record.Key, err)
+			return
+		}
+		fmt.Printf("%s CLOSED to %q\n", record.Key, transition.To.Name)
+
+	case "move":
+		err := r.backend.UpdateIssue(issue.ID, map[string]interface{}{
+			"components": []map[string]string{{"name": r.component}},
+		})
+		if err != nil {
+			r.fail(record.Key, err)
+			return
+		}
+		fmt.Printf("%s MOVED to %s\n", record.Key, r.component)
+	}
+	r.reverted++
+}
+
+func (r *reverter) revertComment(record *audit.Record) {
+	issue, err := r.backend.GetIssue(record.Key, &jira.GetQueryOptions{Fields: "comment"})
+	if err != nil {
+		r.fail(record.Key, err)
+		return
+	}
+
+	var comment *jira.Comment
+	if issue.Fields != nil && issue.Fields.Comments != nil {
+		for _, c := range issue.Fields.Comments.Comments {
+			if c.ID == record.Comment {
+				comment = c
+			}
+		}
+	}
+	switch {
+	case comment == nil:
+		r.skip(record.Key, "comment %s is already gone", record.Comment)
+		return
+	case comment.Updated != comment.Created:
+		r.skip(record.Key, "comment %s was edited by %s", record.Comment, usermap.UserName(&comment.UpdateAuthor))
+		return
+	}
+
+	if err := r.backend.DeleteComment(issue.ID, record.Comment); err != nil {
+		r.fail(record.Key, err)
+		return
+	}
+	fmt.Printf("%s DELETED COMMENT %s\n", record.Key, record.Comment)
+	r.reverted++
+}
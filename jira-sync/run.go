@@ -0,0 +1,576 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/google/go-github/github"
+
+	"github.com/openshift-metal3/jira-sync/pkg/audit"
+	"github.com/openshift-metal3/jira-sync/pkg/bugzilla"
+	"github.com/openshift-metal3/jira-sync/pkg/bugzillaissue"
+	"github.com/openshift-metal3/jira-sync/pkg/githubissue"
+	"github.com/openshift-metal3/jira-sync/pkg/index"
+	"github.com/openshift-metal3/jira-sync/pkg/jirabackend"
+	"github.com/openshift-metal3/jira-sync/pkg/render"
+	"github.com/openshift-metal3/jira-sync/pkg/routing"
+	"github.com/openshift-metal3/jira-sync/pkg/settings"
+	"github.com/openshift-metal3/jira-sync/pkg/stats"
+	"github.com/openshift-metal3/jira-sync/pkg/target"
+	"github.com/openshift-metal3/jira-sync/pkg/usermap"
+	"github.com/openshift-metal3/jira-sync/pkg/versionmap"
+	"github.com/openshift-metal3/jira-sync/pkg/watchers"
+)
+
+// runner holds everything the jobs share, so each server is only
+// asked once for the things that do not change during a run.
+type runner struct {
+	cfg    *config
+	server *settings.Flags
+
+	jiraBackend    jirabackend.Backend
+	jiraClient     *jira.Client
+	githubClient   *github.Client
+	bugzillaClient *bugzilla.Client
+	index          *index.Index
+
+	createMeta map[string]*jira.CreateMetaInfo
+	users      map[string]*usermap.Map
+	versions   map[string]*versionmap.Map
+	routes     map[string]*routing.Rules
+	statusMaps map[string]bugzillaissue.StatusMap
+	watchers   map[string]*watchers.Policy
+	templates  map[string]*render.Templates
+}
+
+// jobResult records the outcome of one job for the summary at the end
+// of the run.
+type jobResult struct {
+	name   string
+	counts *stats.Counts
+	err    error
+	// fatal is set if the job could not run at all, because of its
+	// settings or credentials, rather than failing part way.
+	fatal bool
+}
+
+// failed returns true if some of the upstream tickets could not be
+// imported.
+func (r *jobResult) failed() bool {
+	return r.counts != nil && r.counts.Failures.Len() != 0
+}
+
+func runCommand(cmdArgs []string) int {
+	flags := settings.NewFlagSet("run", "[job name...]",
+		"Run the sync jobs described in the config file, or only the named\n"+
+			"jobs. Server settings in the config file are used when they are\n"+
+			"not given as options or in the environment.")
+	server := settings.NewFlags(flags)
+	server.AddJira(flags)
+	server.AddAudit(flags)
+	server.AddGithub(flags)
+	server.AddBugzilla(flags)
+	configFile := flags.String("config", "", "the YAML file describing the jobs")
+	dryRun := flags.Bool("dry-run", false, "print the changes to jira instead of making them")
+
+	if err := flags.Parse(cmdArgs); err != nil {
+		return settings.ParseExit(err)
+	}
+
+	if *configFile == "" {
+		fmt.Fprintf(os.Stderr, "Please specify the -config file\n")
+		return 1
+	}
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not load config: %v\n", err)
+		return 1
+	}
+
+	if err := server.Resolve(&cfg.Settings); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	jobs, err := selectJobs(cfg.Jobs, flags.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+
+	r, err := newRunner(cfg, server, jobs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	auditLog, err := audit.Open(server.Audit.Log, "run")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	defer auditLog.Close()
+	r.jiraBackend = auditLog.Wrap(r.jiraBackend)
+	var plan *jirabackend.DryRun
+	if *dryRun {
+		// The index only searches, so it can keep the real backend.
+		plan = jirabackend.NewDryRun(r.jiraBackend)
+		r.jiraBackend = plan
+	}
+
+	results := []*jobResult{}
+	for _, j := range jobs {
+		header(j.Name)
+		auditLog.SetJob(j.Name)
+		result := r.runJob(j)
+		if result.err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", result.err)
+		}
+		results = append(results, result)
+	}
+
+	r.showUnmapped()
+	showResults(results)
+	showFailures(results)
+	plan.ShowPlan()
+
+	code := 0
+	for _, result := range results {
+		switch {
+		case result.fatal:
+			return 1
+		case result.err != nil, result.failed():
+			code = settings.ExitFailed
+		}
+	}
+	return code
+}
+
+// selectJobs returns the jobs named on the command line, or all of
+// them if none are named.
+func selectJobs(jobs []*job, names []string) ([]*job, error) {
+	if len(names) == 0 {
+		return jobs, nil
+	}
+	byName := make(map[string]*job)
+	for _, j := range jobs {
+		byName[j.Name] = j
+	}
+	results := []*job{}
+	for _, name := range names {
+		j, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("no job named %q", name)
+		}
+		results = append(results, j)
+	}
+	return results, nil
+}
+
+// newRunner creates the clients needed by the jobs.
+func newRunner(cfg *config, server *settings.Flags, jobs []*job) (*runner, error) {
+	r, err := newJiraRunner(cfg, server)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, j := range jobs {
+		if j.Github != nil && r.githubClient == nil {
+			tc, err := server.GithubHTTPClient()
+			if err != nil {
+				return nil, err
+			}
+			r.githubClient = github.NewClient(tc)
+		}
+		if j.Bugzilla != nil && r.bugzillaClient == nil {
+			r.bugzillaClient, err = server.BugzillaClient(false)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return r, nil
+}
+
+// newJiraRunner creates a runner that only talks to jira.
+func newJiraRunner(cfg *config, server *settings.Flags) (*runner, error) {
+	jiraBackend, err := server.JiraBackend()
+	if err != nil {
+		return nil, err
+	}
+
+	return &runner{
+		cfg:         cfg,
+		server:      server,
+		jiraBackend: jiraBackend,
+		jiraClient:  jiraBackend.Client(),
+		index:       index.New(jiraBackend),
+		createMeta:  make(map[string]*jira.CreateMetaInfo),
+		users:       make(map[string]*usermap.Map),
+		versions:    make(map[string]*versionmap.Map),
+		routes:      make(map[string]*routing.Rules),
+		statusMaps:  make(map[string]bugzillaissue.StatusMap),
+		watchers:    make(map[string]*watchers.Policy),
+		templates:   make(map[string]*render.Templates),
+	}, nil
+}
+
+func header(msg string) {
+	line := strings.Repeat("=", len(msg))
+	fmt.Printf("\n%s\n%s\n%s\n\n", line, msg, line)
+}
+
+func (r *runner) runJob(j *job) *jobResult {
+	result := &jobResult{name: j.Name}
+
+	label := "bugzilla"
+	if j.Github != nil {
+		label = "github"
+	}
+
+	jiraTarget, err := r.target(j)
+	if err != nil {
+		result.err = err
+		result.fatal = true
+		return result
+	}
+
+	// Find the tickets already imported into the project with one
+	// search, instead of one search per upstream ticket.
+	jql := fmt.Sprintf("project = \"%s\" and labels = %s", j.Target.Project, label)
+	count, err := r.index.Load(jql)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	} else if count != 0 {
+		fmt.Printf("found %d %s tickets in %s\n", count, label, j.Target.Project)
+	}
+
+	if j.Github != nil {
+		result.counts, result.err = r.runGithubJob(j, jiraTarget)
+	} else {
+		result.counts, result.err = r.runBugzillaJob(j, jiraTarget)
+	}
+	// The jobs only return counts once they start importing, so an
+	// error without them is in the settings of the job.
+	result.fatal = result.err != nil && (result.counts == nil || authFailed(result.err))
+	return result
+}
+
+// authFailed returns true if github or bugzilla refused the
+// credentials, which the next run will not get past either.
+func authFailed(err error) bool {
+	var githubErr *github.ErrorResponse
+	if errors.As(err, &githubErr) && githubErr.Response != nil &&
+		githubErr.Response.StatusCode == http.StatusUnauthorized {
+		return true
+	}
+	return bugzilla.IsAuth(err)
+}
+
+// target resolves the target of the job, fetching the create metadata
+// for each project only once.
+func (r *runner) target(j *job) (*target.Target, error) {
+	meta, ok := r.createMeta[j.Target.Project]
+	if !ok {
+		var err error
+		meta, err = target.FetchMeta(r.jiraClient, j.Target.Project)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid jira settings: %v", err)
+		}
+		r.createMeta[j.Target.Project] = meta
+	}
+
+	jiraTarget, err := target.Resolve(meta, target.Options{
+		Project:   j.Target.Project,
+		IssueType: j.issueType(),
+		Component: j.Target.Component,
+		Epic:      j.Target.Epic,
+		Parent:    j.Target.Parent,
+		Labels:    j.Target.Labels,
+		Assignee:  j.Target.Assignee,
+		Priority:  j.Target.Priority,
+		Fields:    j.Target.Fields,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Invalid jira settings: %v", err)
+	}
+	if err := jiraTarget.CheckReferences(r.jiraBackend); err != nil {
+		return nil, fmt.Errorf("Invalid jira settings: %v", err)
+	}
+	return jiraTarget, nil
+}
+
+func (r *runner) runGithubJob(j *job, jiraTarget *target.Target) (*stats.Counts, error) {
+	importer := &githubissue.Importer{
+		JiraURL: r.server.Jira.URL,
+		Jira:    r.jiraBackend,
+		Target:  jiraTarget,
+		Index:   r.index,
+		Update:  j.Options.Update,
+	}
+
+	var err error
+	importer.Watchers, err = r.loadWatchers(j.Options.Watchers, j.Options.KeepWatching)
+	if err != nil {
+		return nil, err
+	}
+	importer.Templates, err = r.loadTemplates(j.Options.Templates)
+	if err != nil {
+		return nil, err
+	}
+	if j.Options.RoutingRules != "" {
+		importer.Routes, err = r.loadRoutes(j.Options.RoutingRules, jiraTarget)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if j.Options.UserMap != "" {
+		importer.Users, err = r.loadUsers(j.Options.UserMap)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if j.Options.MilestoneMap != "" {
+		if !jiraTarget.AllowsFixVersions() {
+			return nil, fmt.Errorf("Invalid jira settings: issue type %s in %s does not have fix versions",
+				jiraTarget.IssueTypeName, j.Target.Project)
+		}
+		importer.Versions, err = r.loadVersions(j.Options.MilestoneMap, j.Target.Project,
+			j.Options.CreateVersions)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = importer.ImportSource(r.githubClient, githubissue.Source{
+		Org:    j.Github.Org,
+		Repos:  j.Github.Repos,
+		Label:  j.Github.Label,
+		Ignore: j.Github.Ignore,
+	})
+	return &importer.Counts, err
+}
+
+func (r *runner) runBugzillaJob(j *job, jiraTarget *target.Target) (*stats.Counts, error) {
+	importer := &bugzillaissue.Importer{
+		JiraURL:        r.server.Jira.URL,
+		Jira:           r.jiraBackend,
+		BugzillaClient: r.bugzillaClient,
+		Target:         jiraTarget,
+		Index:          r.index,
+	}
+
+	var err error
+	importer.Watchers, err = r.loadWatchers(j.Options.Watchers, j.Options.KeepWatching)
+	if err != nil {
+		return nil, err
+	}
+	importer.Templates, err = r.loadTemplates(j.Options.Templates)
+	if err != nil {
+		return nil, err
+	}
+
+	if j.Options.SyncStatus {
+		importer.StatusMap, err = r.loadStatusMap(j.Options.StatusMap)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	src := j.Bugzilla
+	var query url.Values
+	switch {
+	case src.Query != "":
+		query, err = r.bugzillaClient.QueryFromBuglistURL(src.Query)
+		if err != nil {
+			return nil, fmt.Errorf("Could not use the bugzilla query: %v", err)
+		}
+	case src.SavedSearch != "":
+		query = bugzilla.SavedSearchQuery(src.SavedSearch, src.SharerID)
+	default:
+		query = bugzillaissue.ProductQuery(src.Product, src.Component)
+	}
+
+	err = importer.ImportQuery(query)
+	return &importer.Counts, err
+}
+
+func (r *runner) loadRoutes(filename string, jiraTarget *target.Target) (*routing.Rules, error) {
+	routes, ok := r.routes[filename]
+	if !ok {
+		var err error
+		routes, err = routing.Load(filename)
+		if err != nil {
+			return nil, fmt.Errorf("Could not load routing rules: %v", err)
+		}
+		r.routes[filename] = routes
+	}
+	// The rules have to be checked against each target, since
+	// the issue types may differ.
+	if err := routes.Check(jiraTarget, r.jiraClient); err != nil {
+		return nil, fmt.Errorf("Invalid routing rules: %v", err)
+	}
+	return routes, nil
+}
+
+func (r *runner) loadUsers(filename string) (*usermap.Map, error) {
+	if users, ok := r.users[filename]; ok {
+		return users, nil
+	}
+	users, err := usermap.Load(filename)
+	if err != nil {
+		return nil, fmt.Errorf("Could not load user map: %v", err)
+	}
+	r.users[filename] = users
+	return users, nil
+}
+
+func (r *runner) loadStatusMap(filename string) (bugzillaissue.StatusMap, error) {
+	if statusMap, ok := r.statusMaps[filename]; ok {
+		return statusMap, nil
+	}
+	statusMap, err := bugzillaissue.LoadStatusMap(filename)
+	if err != nil {
+		return nil, fmt.Errorf("Could not load status map: %v", err)
+	}
+	r.statusMaps[filename] = statusMap
+	return statusMap, nil
+}
+
+// loadTemplates returns the templates in the file, or nil for the
+// defaults if there is no file.
+func (r *runner) loadTemplates(filename string) (*render.Templates, error) {
+	if filename == "" {
+		return nil, nil
+	}
+	if templates, ok := r.templates[filename]; ok {
+		return templates, nil
+	}
+	templates, err := render.Load(filename)
+	if err != nil {
+		return nil, fmt.Errorf("Could not load templates: %v", err)
+	}
+	r.templates[filename] = templates
+	return templates, nil
+}
+
+// loadWatchers returns the watcher policy for the file, which may be
+// empty, and the keep-watching option.
+func (r *runner) loadWatchers(filename string, keepCreator bool) (*watchers.Policy, error) {
+	key := fmt.Sprintf("%s\x00%t", filename, keepCreator)
+	if policy, ok := r.watchers[key]; ok {
+		return policy, nil
+	}
+	policy, err := watchers.New(filename, keepCreator)
+	if err != nil {
+		return nil, fmt.Errorf("Could not load watchers: %v", err)
+	}
+	r.watchers[key] = policy
+	return policy, nil
+}
+
+// loadVersions returns the milestone map for the file and project.
+// The versions exist per project, so the same file used with two
+// projects is loaded twice.
+func (r *runner) loadVersions(filename, project string, create bool) (*versionmap.Map, error) {
+	key := fmt.Sprintf("%s\x00%s\x00%t", filename, project, create)
+	if versions, ok := r.versions[key]; ok {
+		return versions, nil
+	}
+	versions, err := versionmap.Load(filename)
+	if err != nil {
+		return nil, fmt.Errorf("Could not load milestone map: %v", err)
+	}
+	if err := versions.Connect(r.jiraBackend, project, create); err != nil {
+		return nil, fmt.Errorf("Invalid jira settings: %v", err)
+	}
+	r.versions[key] = versions
+	return versions, nil
+}
+
+// showUnmapped lists the github users and milestones seen by all of
+// the jobs that are not in the maps, so the maps can be updated.
+func (r *runner) showUnmapped() {
+	users := make(map[string]bool)
+	for _, m := range r.users {
+		for _, login := range m.Unmapped() {
+			users[login] = true
+		}
+	}
+	milestones := make(map[string]bool)
+	for _, m := range r.versions {
+		for _, milestone := range m.Unmapped() {
+			milestones[milestone] = true
+		}
+	}
+	if len(users) != 0 {
+		fmt.Printf("\nUnmapped github users: %s\n", strings.Join(sortedKeys(users), ", "))
+	}
+	if len(milestones) != 0 {
+		fmt.Printf("\nUnmapped github milestones: %s\n", strings.Join(sortedKeys(milestones), ", "))
+	}
+}
+
+func sortedKeys(values map[string]bool) []string {
+	results := []string{}
+	for value := range values {
+		results = append(results, value)
+	}
+	sort.Strings(results)
+	return results
+}
+
+func showResults(results []*jobResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "\nJOB\tRESULT\tTICKETS\n")
+	for _, result := range results {
+		status := "OK"
+		switch {
+		case result.err != nil:
+			status = "FAILED"
+		case result.failed():
+			status = "PARTIAL"
+		}
+		counts := "-"
+		if result.counts != nil {
+			counts = result.counts.String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", result.name, status, counts)
+	}
+	w.Flush()
+}
+
+// showFailures prints the upstream tickets that could not be imported
+// by any of the jobs, and the jobs that stopped part way, so they do
+// not have to be found in the output.
+func showFailures(results []*jobResult) {
+	var w *tabwriter.Writer
+	show := func(job, item string, err error) {
+		if w == nil {
+			w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintf(w, "\nJOB\tFAILED\tERROR\n")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", job, item, stats.OneLine(err.Error()))
+	}
+	for _, result := range results {
+		if result.counts == nil {
+			continue
+		}
+		for _, failure := range result.counts.Failures.List() {
+			show(result.name, failure.Item, failure.Err)
+		}
+		if result.err != nil && !result.fatal {
+			show(result.name, "-", result.err)
+		}
+	}
+	if w != nil {
+		w.Flush()
+	}
+}
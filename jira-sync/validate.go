@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/openshift-metal3/jira-sync/pkg/settings"
+	"github.com/openshift-metal3/jira-sync/pkg/target"
+)
+
+func validateCommand(cmdArgs []string) int {
+	flags := settings.NewFlagSet("validate", "[job name...]",
+		"Check the jira settings of the sync jobs in the config file, or only\n"+
+			"the named jobs, against the create metadata of the projects. Without\n"+
+			"-config the target given by the options is checked instead. Nothing\n"+
+			"is changed in jira.")
+	server := settings.NewFlags(flags)
+	server.AddJira(flags)
+	configFile := flags.String("config", "", "the YAML file describing the jobs")
+	jiraProject := flags.String("jira-project", "", "the jira project")
+	jiraComponent := flags.String("jira-component", "", "the jira component for new tickets")
+	jiraIssueType := flags.String("jira-issue-type", "story", "the jira issue type for new tickets")
+	jiraEpic := flags.String("jira-epic", "", "the key of the epic to link new tickets to")
+	jiraParent := flags.String("jira-parent", "", "the key of the parent for new sub-tasks")
+	jiraLabels := flags.String("jira-labels", "", "comma separated extra labels for new tickets")
+	jiraAssignee := flags.String("jira-assignee", "", "the user to assign new tickets to")
+	jiraPriority := flags.String("jira-priority", "", "the priority of new tickets")
+	jiraFieldsFile := flags.String("jira-fields", "", "YAML file with values for other fields of new tickets, such as custom fields")
+
+	if err := flags.Parse(cmdArgs); err != nil {
+		return settings.ParseExit(err)
+	}
+
+	cfg := &config{}
+	var jobs []*job
+	switch {
+	case *configFile != "":
+		var err error
+		cfg, err = loadConfig(*configFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not load config: %v\n", err)
+			return 1
+		}
+		jobs, err = selectJobs(cfg.Jobs, flags.Args())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			return 1
+		}
+	case *jiraProject != "":
+		var jiraFields map[string]string
+		if *jiraFieldsFile != "" {
+			var err error
+			jiraFields, err = target.LoadFields(*jiraFieldsFile)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Could not load fields: %v\n", err)
+				return 1
+			}
+		}
+		jobs = []*job{{
+			Name: "command line",
+			Target: targetConfig{
+				Project:   *jiraProject,
+				Component: *jiraComponent,
+				IssueType: *jiraIssueType,
+				Epic:      *jiraEpic,
+				Parent:    *jiraParent,
+				Labels:    target.ParseLabels(*jiraLabels),
+				Assignee:  *jiraAssignee,
+				Priority:  *jiraPriority,
+				Fields:    jiraFields,
+			},
+		}}
+	default:
+		fmt.Fprintf(os.Stderr, "Please specify the -config file or the -jira-project\n")
+		return 1
+	}
+
+	if err := server.Resolve(&cfg.Settings); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	r, err := newJiraRunner(cfg, server)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+
+	failed := false
+	for _, j := range jobs {
+		problems, notes := r.validateJob(j)
+		if len(problems) == 0 {
+			fmt.Printf("OK    %s\n", j.Name)
+		} else {
+			failed = true
+			fmt.Printf("FAIL  %s\n", j.Name)
+		}
+		for _, problem := range problems {
+			fmt.Printf("      %v\n", problem)
+		}
+		for _, note := range notes {
+			fmt.Printf("      %s\n", note)
+		}
+	}
+
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// validateJob runs the checks the job makes before importing
+// anything, and returns all of the problems found along with notes
+// about what the job would change.
+func (r *runner) validateJob(j *job) (problems []error, notes []string) {
+	jiraTarget, err := r.target(j)
+	if err != nil {
+		// The other checks need the target.
+		return []error{err}, nil
+	}
+
+	if j.Options.RoutingRules != "" {
+		if _, err := r.loadRoutes(j.Options.RoutingRules, jiraTarget); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	if j.Options.UserMap != "" {
+		if _, err := r.loadUsers(j.Options.UserMap); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	if _, err := r.loadWatchers(j.Options.Watchers, j.Options.KeepWatching); err != nil {
+		problems = append(problems, err)
+	}
+	if _, err := r.loadTemplates(j.Options.Templates); err != nil {
+		problems = append(problems, err)
+	}
+	if j.Options.SyncStatus {
+		if _, err := r.loadStatusMap(j.Options.StatusMap); err != nil {
+			problems = append(problems, err)
+		}
+	}
+
+	if j.Options.MilestoneMap != "" {
+		if !jiraTarget.AllowsFixVersions() {
+			problems = append(problems, fmt.Errorf("issue type %s in %s does not have fix versions",
+				jiraTarget.IssueTypeName, j.Target.Project))
+			return problems, notes
+		}
+		// Connecting the map only reads the versions, they are
+		// created when the first ticket needs them.
+		versions, err := r.loadVersions(j.Options.MilestoneMap, j.Target.Project,
+			j.Options.CreateVersions)
+		if err != nil {
+			return append(problems, err), notes
+		}
+		missing := versions.Missing()
+		if len(missing) != 0 && j.Options.CreateVersions {
+			notes = append(notes, fmt.Sprintf("versions to create in %s: %s",
+				j.Target.Project, strings.Join(missing, ", ")))
+		} else if len(missing) != 0 {
+			problems = append(problems, fmt.Errorf(
+				"versions %s are not in %s, choose from: %s, or use create-versions",
+				strings.Join(missing, ", "), j.Target.Project,
+				strings.Join(versions.Existing(), ", ")))
+		}
+	}
+
+	return problems, notes
+}
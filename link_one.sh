@@ -2,7 +2,7 @@
 
 set -e
 
-bugzilla_one=$HOME/go/bin/bugzilla-one
+jira_sync=$HOME/go/bin/jira-sync
 
 source $HOME/.jira_sync_settings
 
@@ -11,12 +11,8 @@ if [ -z "$jira_url" ]; then
     exit 1
 fi
 
-$bugzilla_one \
-    -jira-user "$jira_user" \
-    -jira-password "$jira_password" \
-    -jira-url "$jira_url" \
-    -bugzilla-token "$bugzilla_token" \
-    -bugzilla-url "$bugzilla_url" \
+$jira_sync bugzilla-one \
+    -settings $HOME/.jira_sync_settings \
     -jira-project KNIDEPLOY \
     -jira-component 'KNI Deploy Install' \
     $@
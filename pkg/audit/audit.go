@@ -0,0 +1,187 @@
+// Package audit keeps an append-only log of the changes the commands
+// make in jira, so that the reason a ticket exists or was changed can
+// be found long after the output of the run is gone.
+package audit
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Record describes one change made in jira. The log holds one record
+// per line, in JSON.
+type Record struct {
+	Time time.Time `json:"time"`
+	// Run identifies the run of a command that made the change, for
+	// reverting it.
+	Run     string `json:"run,omitempty"`
+	Command string `json:"command"`
+	Job     string `json:"job,omitempty"`
+	// User is the jira user that made the change, as a username or
+	// an account ID with the accountid: prefix.
+	User string `json:"user,omitempty"`
+	// Slug names the upstream ticket the jira ticket was imported
+	// from, if it is known.
+	Slug string `json:"slug,omitempty"`
+	Key  string `json:"key,omitempty"`
+	// Comment is the ID of the comment added.
+	Comment string `json:"comment,omitempty"`
+	// Action is one of create, comment, transition, assign, update,
+	// attach, add-watcher, remove-watcher, create-version, delete, or
+	// delete-comment.
+	Action  string `json:"action"`
+	Summary string `json:"summary"`
+	// Error is set if jira refused the change.
+	Error string `json:"error,omitempty"`
+}
+
+// Log appends records to the audit log file. A nil Log records
+// nothing.
+type Log struct {
+	command string
+	run     string
+
+	mutex   sync.Mutex
+	file    *os.File
+	job     string
+	written int
+}
+
+// Open opens the log file for appending, creating it and its directory
+// if needed. The records are made by the named command. Without a
+// filename there is no log, and Open returns nil.
+func Open(filename, command string) (*Log, error) {
+	if filename == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return nil, fmt.Errorf("Could not open audit log: %v", err)
+	}
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("Could not open audit log: %v", err)
+	}
+	return &Log{command: command, run: newRunID(), file: file}, nil
+}
+
+// newRunID makes an ID that sorts by the start of the run and is
+// unique even if runs start at the same time.
+func newRunID() string {
+	random := make([]byte, 3)
+	rand.Read(random)
+	return time.Now().UTC().Format("20060102-150405-") + hex.EncodeToString(random)
+}
+
+// Close closes the log file. If the run made any changes, the run ID
+// is printed so they can be found again.
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.written != 0 {
+		fmt.Printf("\nRecorded %d changes in the audit log as run %s\n", l.written, l.run)
+	}
+	return l.file.Close()
+}
+
+// SetJob names the job the following records are made for.
+func (l *Log) SetJob(name string) {
+	if l == nil {
+		return
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.job = name
+}
+
+// add fills in the time, command, and job and appends the record.
+// Failing to write the log does not stop the run, since the change has
+// been made in jira either way.
+func (l *Log) add(r Record) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	r.Time = time.Now().UTC()
+	r.Run = l.run
+	r.Command = l.command
+	r.Job = l.job
+	line, err := json.Marshal(r)
+	if err == nil {
+		// One write per record keeps the lines whole when more
+		// than one run appends to the same file.
+		_, err = l.file.Write(append(line, '\n'))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Could not write audit log: %s\n", err)
+		return
+	}
+	l.written++
+}
+
+// Filter selects records. Empty fields match everything.
+type Filter struct {
+	Run  string
+	Key  string
+	Slug string
+	// Since and Until limit the time of the records, Until being
+	// exclusive.
+	Since time.Time
+	Until time.Time
+}
+
+// Match returns true if the record passes the filter.
+func (f Filter) Match(r *Record) bool {
+	if f.Run != "" && f.Run != r.Run {
+		return false
+	}
+	if f.Key != "" && !strings.EqualFold(f.Key, r.Key) {
+		return false
+	}
+	if f.Slug != "" && f.Slug != r.Slug {
+		return false
+	}
+	if !f.Since.IsZero() && r.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !r.Time.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+// Read returns the records in the log file that pass the filter, in
+// the order they were written.
+func Read(filename string, filter Filter) ([]*Record, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	results := []*Record{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		r := &Record{}
+		if err := json.Unmarshal(scanner.Bytes(), r); err != nil {
+			return nil, fmt.Errorf("Unable to parse audit log %s: line %d: %s", filename, n, err)
+		}
+		if filter.Match(r) {
+			results = append(results, r)
+		}
+	}
+	return results, scanner.Err()
+}
@@ -0,0 +1,231 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/andygrunwald/go-jira"
+
+	"github.com/openshift-metal3/jira-sync/pkg/index"
+	"github.com/openshift-metal3/jira-sync/pkg/jirabackend"
+	"github.com/openshift-metal3/jira-sync/pkg/render"
+	"github.com/openshift-metal3/jira-sync/pkg/usermap"
+)
+
+// maxSummary is the longest comment text kept in a record.
+const maxSummary = 200
+
+// backend records the changes made through the backend it wraps.
+type backend struct {
+	backend jirabackend.Backend
+	log     *Log
+
+	mutex   sync.Mutex
+	tickets map[string]ticket
+	user    string
+}
+
+// ticket is what the records say about a jira ticket.
+type ticket struct {
+	key  string
+	slug string
+}
+
+// Wrap returns a backend that records each change made through the
+// given one in the log. Without a log the backend is returned as is.
+func (l *Log) Wrap(b jirabackend.Backend) jirabackend.Backend {
+	if l == nil {
+		return b
+	}
+	return &backend{
+		backend: b,
+		log:     l,
+		tickets: make(map[string]ticket),
+	}
+}
+
+func (b *backend) remember(issue *jira.Issue, summary string) ticket {
+	t := ticket{key: issue.Key}
+	if slugs := index.Slugs(summary); len(slugs) != 0 {
+		t.slug = slugs[0]
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.tickets[issue.ID] = t
+	b.tickets[issue.Key] = t
+	return t
+}
+
+// ticket looks up the key and slug of the ticket. The commands change
+// tickets by ID as often as by key, and the slug is in the summary.
+func (b *backend) ticket(issueID string) ticket {
+	b.mutex.Lock()
+	t, ok := b.tickets[issueID]
+	b.mutex.Unlock()
+	if ok {
+		return t
+	}
+	issue, err := b.backend.GetIssue(issueID, &jira.GetQueryOptions{Fields: "summary"})
+	if err != nil || issue.Fields == nil {
+		return ticket{key: issueID}
+	}
+	return b.remember(issue, issue.Fields.Summary)
+}
+
+// actor returns the user the changes are made as, looking it up the
+// first time.
+func (b *backend) actor() string {
+	b.mutex.Lock()
+	user := b.user
+	b.mutex.Unlock()
+	if user != "" {
+		return user
+	}
+	myself, err := b.backend.Myself()
+	if err != nil {
+		return ""
+	}
+	user = usermap.FormatUser(myself)
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.user = user
+	return user
+}
+
+func (b *backend) add(issueID, action, summary string, err error) {
+	b.addRecord(issueID, Record{Action: action, Summary: summary}, err)
+}
+
+func (b *backend) addRecord(issueID string, r Record, err error) {
+	if issueID != "" {
+		t := b.ticket(issueID)
+		r.Key, r.Slug = t.key, t.slug
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	r.User = b.actor()
+	b.log.add(r)
+}
+
+func (b *backend) Client() *jira.Client {
+	return b.backend.Client()
+}
+
+func (b *backend) CreateIssue(issue *jira.Issue) (*jira.Issue, error) {
+	created, err := b.backend.CreateIssue(issue)
+	summary := fmt.Sprintf("%s in %s: %s", issue.Fields.Type.Name, issue.Fields.Project.Key,
+		issue.Fields.Summary)
+	r := Record{Action: "create", Summary: summary}
+	if err != nil {
+		if slugs := index.Slugs(issue.Fields.Summary); len(slugs) != 0 {
+			r.Slug = slugs[0]
+		}
+	} else {
+		t := b.remember(created, issue.Fields.Summary)
+		r.Key, r.Slug = t.key, t.slug
+	}
+	b.addRecord("", r, err)
+	return created, err
+}
+
+func (b *backend) GetIssue(key string, options *jira.GetQueryOptions) (*jira.Issue, error) {
+	return b.backend.GetIssue(key, options)
+}
+
+func (b *backend) Search(jql string, options *jira.SearchOptions) ([]jira.Issue, error) {
+	return b.backend.Search(jql, options)
+}
+
+func (b *backend) SearchPages(jql string, options *jira.SearchOptions, f func(jira.Issue) error) error {
+	return b.backend.SearchPages(jql, options, f)
+}
+
+func (b *backend) AddComment(issueID, body string) (string, error) {
+	commentID, err := b.backend.AddComment(issueID, body)
+	b.addRecord(issueID, Record{
+		Action:  "comment",
+		Comment: commentID,
+		Summary: render.Truncate(body, maxSummary),
+	}, err)
+	return commentID, err
+}
+
+func (b *backend) Myself() (*jira.User, error) {
+	return b.backend.Myself()
+}
+
+func (b *backend) FindUser(user *jira.User) (*jira.User, error) {
+	return b.backend.FindUser(user)
+}
+
+func (b *backend) AddWatcher(issueID string, user *jira.User) error {
+	err := b.backend.AddWatcher(issueID, user)
+	b.add(issueID, "add-watcher", userName(user), err)
+	return err
+}
+
+func (b *backend) RemoveWatcher(issueID string, user *jira.User) error {
+	err := b.backend.RemoveWatcher(issueID, user)
+	b.add(issueID, "remove-watcher", userName(user), err)
+	return err
+}
+
+func (b *backend) UpdateIssue(issueID string, fields map[string]interface{}) error {
+	err := b.backend.UpdateIssue(issueID, fields)
+	summary, _ := json.Marshal(fields)
+	b.add(issueID, "update", string(summary), err)
+	return err
+}
+
+func (b *backend) SetAssignee(issueID string, user *jira.User) error {
+	err := b.backend.SetAssignee(issueID, user)
+	b.add(issueID, "assign", userName(user), err)
+	return err
+}
+
+func (b *backend) DoTransition(issueID, transitionID string) error {
+	err := b.backend.DoTransition(issueID, transitionID)
+	b.add(issueID, "transition", "transition "+transitionID, err)
+	return err
+}
+
+func (b *backend) AddAttachment(issueID, name string, content io.Reader) error {
+	err := b.backend.AddAttachment(issueID, name, content)
+	b.add(issueID, "attach", name, err)
+	return err
+}
+
+func (b *backend) CreateVersion(version *jira.Version) (*jira.Version, error) {
+	created, err := b.backend.CreateVersion(version)
+	summary := fmt.Sprintf("%s in project %d", version.Name, version.ProjectID)
+	b.add("", "create-version", summary, err)
+	return created, err
+}
+
+func (b *backend) DeleteIssue(issueID string) error {
+	// Look the ticket up first, since it cannot be afterwards.
+	t := b.ticket(issueID)
+	err := b.backend.DeleteIssue(issueID)
+	b.add(t.key, "delete", "delete "+t.key, err)
+	return err
+}
+
+func (b *backend) DeleteComment(issueID, commentID string) error {
+	err := b.backend.DeleteComment(issueID, commentID)
+	b.addRecord(issueID, Record{
+		Action:  "delete-comment",
+		Comment: commentID,
+		Summary: "delete comment " + commentID,
+	}, err)
+	return err
+}
+
+func userName(user *jira.User) string {
+	if user == nil {
+		return ""
+	}
+	return usermap.UserName(user)
+}
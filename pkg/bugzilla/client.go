@@ -0,0 +1,363 @@
+// Package bugzilla is a small client for the bugzilla REST API,
+// covering the calls the jira-sync commands need.
+package bugzilla
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openshift-metal3/jira-sync/pkg/retry"
+)
+
+// AuthMode selects how credentials are passed to bugzilla.
+type AuthMode string
+
+const (
+	// AuthNone sends no credentials, so only public bugs are visible.
+	AuthNone AuthMode = "none"
+	// AuthHeader sends the API key in the X-BUGZILLA-API-KEY header.
+	AuthHeader AuthMode = "header"
+	// AuthQuery sends the API key as the api_key query parameter.
+	AuthQuery AuthMode = "query"
+	// AuthLogin exchanges a username and password for a token.
+	AuthLogin AuthMode = "login"
+)
+
+// DefaultFields is the list of fields requested when the caller does
+// not ask for something else.
+const DefaultFields = "id,alias,status,summary,description"
+
+// Credentials holds the settings for authenticating with bugzilla.
+type Credentials struct {
+	Mode     AuthMode
+	APIKey   string
+	User     string
+	Password string
+}
+
+// Validate ensures the values needed by the auth mode are present.
+func (c Credentials) Validate() error {
+	switch c.Mode {
+	case AuthNone:
+	case AuthHeader, AuthQuery:
+		if c.APIKey == "" {
+			return fmt.Errorf("bugzilla auth mode %q requires an API token", c.Mode)
+		}
+	case AuthLogin:
+		if c.User == "" || c.Password == "" {
+			return fmt.Errorf("bugzilla auth mode %q requires a user and password", c.Mode)
+		}
+	default:
+		return fmt.Errorf("unknown bugzilla auth mode %q, use one of none, header, query, or login", c.Mode)
+	}
+	return nil
+}
+
+// Bug holds the fields of a bug the commands work with.
+type Bug struct {
+	ID           int       `json:"id"`
+	Alias        []string  `json:"alias"`
+	Status       string    `json:"status"`
+	Summary      string    `json:"summary"`
+	Description  string    `json:"description"`
+	Product      string    `json:"product"`
+	Severity     string    `json:"severity"`
+	Priority     string    `json:"priority"`
+	Creator      string    `json:"creator"`
+	CreationTime time.Time `json:"creation_time"`
+}
+
+type bugSet struct {
+	Error   bool   `json:"error"`
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Bugs    []Bug  `json:"bugs"`
+}
+
+type loginResult struct {
+	Error   bool   `json:"error"`
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Token   string `json:"token"`
+}
+
+// Client talks to one bugzilla server.
+type Client struct {
+	baseURL     *url.URL
+	credentials Credentials
+	httpClient  *http.Client
+
+	loginMutex sync.Mutex
+	token      string
+}
+
+// NewClient returns a client for the server at baseURL.
+func NewClient(baseURL string, credentials Credentials) (*Client, error) {
+	if err := credentials.Validate(); err != nil {
+		return nil, err
+	}
+	parsedURL, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse bugzilla URL %s: %s", baseURL, err)
+	}
+	// The timeout is for each attempt, including reading the
+	// response, so it does not cut short waiting for a retry.
+	transport := retry.New(nil)
+	transport.Timeout = time.Second * 20
+	return &Client{
+		baseURL:     parsedURL,
+		credentials: credentials,
+		httpClient: &http.Client{
+			Transport: transport,
+		},
+	}, nil
+}
+
+// URL returns the base URL of the server.
+func (c *Client) URL() string {
+	return c.baseURL.String()
+}
+
+// ShowBugURL returns the URL of the UI page for the bug.
+func (c *Client) ShowBugURL(id int) string {
+	return fmt.Sprintf("%s/show_bug.cgi?id=%d", c.URL(), id)
+}
+
+// Search returns the bugs matching the query parameters.
+func (c *Client) Search(query url.Values) ([]Bug, error) {
+	return c.getBugs("rest/bug", query)
+}
+
+// Get returns one bug, looked up by ID or alias.
+func (c *Client) Get(id string, fields string) (*Bug, error) {
+	query := url.Values{}
+	if fields != "" {
+		query.Set("include_fields", fields)
+	}
+	bugs, err := c.getBugs(fmt.Sprintf("rest/bug/%s", url.PathEscape(id)), query)
+	if err != nil {
+		return nil, err
+	}
+	if len(bugs) == 0 {
+		return nil, &NotFoundError{Err: &Error{Code: 101, Message: fmt.Sprintf("bug %s", id)}}
+	}
+	return &bugs[0], nil
+}
+
+// GetMany returns the bugs with the given IDs or aliases using a
+// single request. Bugs that do not exist or cannot be seen with the
+// current credentials are left out of the results.
+func (c *Client) GetMany(ids []string, fields string) ([]Bug, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	numeric := []string{}
+	aliases := []string{}
+	for _, id := range ids {
+		if _, err := strconv.Atoi(id); err == nil {
+			numeric = append(numeric, id)
+		} else {
+			aliases = append(aliases, id)
+		}
+	}
+
+	// Combine the ID and alias searches with OR so that both kinds
+	// of reference can be resolved at once.
+	query := url.Values{}
+	query.Set("j_top", "OR")
+	n := 1
+	if len(numeric) > 0 {
+		query.Set(fmt.Sprintf("f%d", n), "bug_id")
+		query.Set(fmt.Sprintf("o%d", n), "anyexact")
+		query.Set(fmt.Sprintf("v%d", n), strings.Join(numeric, ","))
+		n++
+	}
+	if len(aliases) > 0 {
+		query.Set(fmt.Sprintf("f%d", n), "alias")
+		query.Set(fmt.Sprintf("o%d", n), "anyexact")
+		query.Set(fmt.Sprintf("v%d", n), strings.Join(aliases, ","))
+	}
+	if fields != "" {
+		query.Set("include_fields", fields)
+	}
+	return c.getBugs("rest/bug", query)
+}
+
+// getBugs runs the query, logging in again once if the saved login
+// token is no longer accepted.
+func (c *Client) getBugs(path string, query url.Values) ([]Bug, error) {
+	if query.Get("include_fields") == "" {
+		query.Set("include_fields", DefaultFields)
+	}
+
+	bugs, err := c.queryBugs(path, query)
+	if IsAuth(err) && c.credentials.Mode == AuthLogin && c.forgetToken() {
+		bugs, err = c.queryBugs(path, query)
+	}
+	return bugs, err
+}
+
+func (c *Client) queryBugs(path string, query url.Values) ([]Bug, error) {
+	body, err := c.get(path, query)
+	if err != nil {
+		return nil, err
+	}
+
+	result := bugSet{}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("Unable to parse bugzilla response: %s: %s", c.redact(string(body)), err)
+	}
+	if result.Error {
+		return nil, classify(&Error{Code: result.Code, Message: result.Message})
+	}
+	return result.Bugs, nil
+}
+
+// get sends the request with the credentials added and returns the
+// body of the response.
+func (c *Client) get(path string, query url.Values) ([]byte, error) {
+	requestURL := *c.baseURL
+	requestURL.Path = fmt.Sprintf("%s/%s", c.baseURL.Path, path)
+
+	q := url.Values{}
+	for k, v := range query {
+		q[k] = v
+	}
+
+	header := http.Header{}
+	header.Set("User-Agent", "jira-sync")
+
+	switch c.credentials.Mode {
+	case AuthHeader:
+		header.Set("X-BUGZILLA-API-KEY", c.credentials.APIKey)
+	case AuthQuery:
+		q.Set("api_key", c.credentials.APIKey)
+	case AuthLogin:
+		token, err := c.login()
+		if err != nil {
+			return nil, err
+		}
+		q.Set("token", token)
+	}
+	requestURL.RawQuery = q.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, requestURL.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to build request: %s", errorWithoutURL(err))
+	}
+	req.Header = header
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		// The error includes the URL, which may include the
+		// credentials, so only report the path.
+		return nil, fmt.Errorf("Unable to query bugzilla %s: %s", path, errorWithoutURL(err))
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode == http.StatusUnauthorized {
+		envelope := Error{Code: 410, Message: res.Status}
+		json.Unmarshal(body, &envelope)
+		return nil, &AuthError{Err: &envelope}
+	}
+	return body, nil
+}
+
+// login exchanges the username and password for a token the first
+// time it is called and returns the saved token after that, until
+// forgetToken clears it.
+func (c *Client) login() (string, error) {
+	c.loginMutex.Lock()
+	defer c.loginMutex.Unlock()
+
+	if c.token != "" {
+		return c.token, nil
+	}
+
+	loginURL := *c.baseURL
+	loginURL.Path = fmt.Sprintf("%s/rest/login", c.baseURL.Path)
+	q := url.Values{}
+	q.Set("login", c.credentials.User)
+	q.Set("password", c.credentials.Password)
+	loginURL.RawQuery = q.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, loginURL.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("Unable to build login request: %s", errorWithoutURL(err))
+	}
+	req.Header.Set("User-Agent", "jira-sync")
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("Unable to log in to bugzilla: %s", errorWithoutURL(err))
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+
+	result := loginResult{}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("Unable to parse bugzilla login response: %s", err)
+	}
+	if result.Error {
+		return "", &AuthError{Err: &Error{Code: result.Code, Message: result.Message}}
+	}
+	if result.Token == "" {
+		return "", &AuthError{Err: &Error{Code: 300, Message: "no token in login response"}}
+	}
+
+	c.token = result.Token
+	return c.token, nil
+}
+
+// forgetToken clears the saved login token, so that the next request
+// logs in again, and returns false if there was no token to clear.
+func (c *Client) forgetToken() bool {
+	c.loginMutex.Lock()
+	defer c.loginMutex.Unlock()
+
+	hadToken := c.token != ""
+	c.token = ""
+	return hadToken
+}
+
+// redact hides the credentials in text from the server, such as an
+// error page that repeats the request URL.
+func (c *Client) redact(text string) string {
+	c.loginMutex.Lock()
+	token := c.token
+	c.loginMutex.Unlock()
+
+	for _, secret := range []string{c.credentials.APIKey, c.credentials.Password, token} {
+		if secret != "" {
+			text = strings.ReplaceAll(text, secret, "********")
+			text = strings.ReplaceAll(text, url.QueryEscape(secret), "********")
+		}
+	}
+	return text
+}
+
+// errorWithoutURL strips the request URL out of errors from the
+// http client.
+func errorWithoutURL(err error) error {
+	if urlErr, ok := err.(*url.Error); ok {
+		return urlErr.Err
+	}
+	return err
+}
@@ -0,0 +1,88 @@
+package bugzilla
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error is the error envelope bugzilla returns in the body of a
+// failed REST call.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("bugzilla error %d: %s", e.Code, e.Message)
+}
+
+// AuthError is returned when bugzilla rejects the credentials or the
+// credentials do not allow access to a bug.
+type AuthError struct {
+	Err *Error
+}
+
+func (e *AuthError) Error() string {
+	return fmt.Sprintf("bugzilla authentication failed: %s", e.Err.Message)
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+// NotFoundError is returned when a bug ID or alias does not exist.
+type NotFoundError struct {
+	Err *Error
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("bug not found: %s", e.Err.Message)
+}
+
+func (e *NotFoundError) Unwrap() error {
+	return e.Err
+}
+
+// IsAuth returns true if the error was caused by missing or invalid
+// credentials.
+func IsAuth(err error) bool {
+	var authErr *AuthError
+	return errors.As(err, &authErr)
+}
+
+// IsNotFound returns true if the error was caused by a bug that does
+// not exist.
+func IsNotFound(err error) bool {
+	var notFoundErr *NotFoundError
+	return errors.As(err, &notFoundErr)
+}
+
+// Error codes from the bugzilla WebService API documentation that we
+// want to report as something other than a generic failure.
+var (
+	notFoundCodes = map[int]bool{
+		100: true, // invalid bug alias
+		101: true, // invalid bug ID
+	}
+	authCodes = map[int]bool{
+		102: true, // access denied
+		300: true, // invalid username or password
+		301: true, // account disabled
+		305: true, // new password required
+		306: true, // API key does not match login
+		307: true, // invalid or expired token
+		410: true, // login required
+	}
+)
+
+// classify wraps the error envelope in the type that matches its
+// code.
+func classify(e *Error) error {
+	switch {
+	case notFoundCodes[e.Code]:
+		return &NotFoundError{Err: e}
+	case authCodes[e.Code]:
+		return &AuthError{Err: e}
+	}
+	return e
+}
@@ -0,0 +1,101 @@
+package bugzilla
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// restFieldNames maps the parameter names used by buglist.cgi to the
+// names the REST search API expects.
+var restFieldNames = map[string]string{
+	"bug_id":            "id",
+	"bug_status":        "status",
+	"bug_severity":      "severity",
+	"short_desc":        "summary",
+	"rep_platform":      "platform",
+	"bug_file_loc":      "url",
+	"reporter":          "creator",
+	"status_whiteboard": "whiteboard",
+}
+
+// uiOnlyParameters are buglist.cgi parameters that control how the
+// results are displayed, which mean nothing to the REST API.
+var uiOnlyParameters = map[string]bool{
+	"query_format":       true,
+	"list_id":            true,
+	"order":              true,
+	"columnlist":         true,
+	"ctype":              true,
+	"query_based_on":     true,
+	"known_name":         true,
+	"remaction":          true,
+	"format":             true,
+	"human":              true,
+	"include_fields":     true,
+	"api_key":            true,
+	"token":              true,
+	"Bugzilla_api_token": true,
+}
+
+// QueryFromBuglistURL translates the parameters of a buglist.cgi URL,
+// as copied from the browser, into parameters for the REST search
+// API. Links to a named saved search are turned into a saved search
+// query.
+func (c *Client) QueryFromBuglistURL(buglistURL string) (url.Values, error) {
+	parsedURL, err := url.Parse(buglistURL)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse bugzilla query URL %s: %s", buglistURL, err)
+	}
+	if parsedURL.Host != "" && !strings.EqualFold(parsedURL.Host, c.baseURL.Host) {
+		return nil, fmt.Errorf("The bugzilla query URL %s is not on the server %s",
+			buglistURL, c.URL())
+	}
+	if !strings.HasSuffix(parsedURL.Path, "buglist.cgi") {
+		return nil, fmt.Errorf("The bugzilla query URL %s is not a buglist.cgi URL", buglistURL)
+	}
+
+	in := parsedURL.Query()
+	if in.Get("cmdtype") == "runnamed" || in.Get("cmdtype") == "dorem" {
+		name := in.Get("namedcmd")
+		if name == "" {
+			return nil, fmt.Errorf("The bugzilla query URL %s has no saved search name", buglistURL)
+		}
+		return SavedSearchQuery(name, in.Get("sharer_id")), nil
+	}
+
+	out := url.Values{}
+	for name, values := range in {
+		if uiOnlyParameters[name] || name == "cmdtype" || name == "namedcmd" {
+			continue
+		}
+		if restName, ok := restFieldNames[name]; ok {
+			name = restName
+		}
+		for _, v := range values {
+			if v == "" {
+				// The search form sends every field, even when it
+				// is empty, and those should not be treated as
+				// filters.
+				continue
+			}
+			out.Add(name, v)
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("The bugzilla query URL %s has no search terms", buglistURL)
+	}
+	return out, nil
+}
+
+// SavedSearchQuery returns the parameters for running a saved search
+// by name. The sharerID is only needed for searches shared by another
+// user.
+func SavedSearchQuery(name, sharerID string) url.Values {
+	q := url.Values{}
+	q.Set("savedsearch", name)
+	if sharerID != "" {
+		q.Set("sharer_id", sharerID)
+	}
+	return q
+}
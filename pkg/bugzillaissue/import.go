@@ -0,0 +1,214 @@
+// Package bugzillaissue imports bugzilla bugs into jira, so that the
+// bulk and single bug commands create identical tickets.
+package bugzillaissue
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+
+	"github.com/openshift-metal3/jira-sync/pkg/bugzilla"
+	"github.com/openshift-metal3/jira-sync/pkg/index"
+	"github.com/openshift-metal3/jira-sync/pkg/jirabackend"
+	"github.com/openshift-metal3/jira-sync/pkg/markup"
+	"github.com/openshift-metal3/jira-sync/pkg/render"
+	"github.com/openshift-metal3/jira-sync/pkg/stats"
+	"github.com/openshift-metal3/jira-sync/pkg/target"
+	"github.com/openshift-metal3/jira-sync/pkg/watchers"
+)
+
+// Importer creates jira tickets for bugzilla bugs.
+type Importer struct {
+	JiraURL        string
+	Jira           jirabackend.Backend
+	BugzillaClient *bugzilla.Client
+	Target         *target.Target
+
+	// Index finds the tickets already imported. It may be shared
+	// between importers.
+	Index *index.Index
+
+	// StatusMap turns on moving existing tickets through the jira
+	// workflow to match the bug status. It may be nil.
+	StatusMap StatusMap
+
+	// Watchers chooses who watches new tickets. It may be nil.
+	Watchers *watchers.Policy
+
+	// Templates build the text of new tickets. It may be nil.
+	Templates *render.Templates
+
+	// Counts tracks the bugs imported.
+	Counts stats.Counts
+}
+
+// ImportFields are the bug fields the importer uses.
+const ImportFields = "id,alias,status,summary,description,product,severity,priority,creator,creation_time"
+
+// ProductQuery builds the default search for all of the open bugs in
+// a product and, optionally, a component.
+func ProductQuery(product, component string) url.Values {
+	q := url.Values{}
+	q.Set("product", product)
+	q.Add("status", "NEW")
+	q.Add("status", "ASSIGNED")
+	q.Add("status", "POST")
+	q.Add("status", "MODIFIED")
+	q.Add("status", "ON_DEV")
+	q.Add("status", "ON_QA")
+	q.Add("status", "VERIFIED")
+	q.Add("status", "RELEASE_PENDING")
+	if component != "" {
+		q.Set("component", component)
+	}
+	return q
+}
+
+// Slug builds a unique string to use as a search term to find jira
+// tickets based on the bugzilla ticket.
+func Slug(bugID int) string {
+	return fmt.Sprintf("bugzilla:%d", bugID)
+}
+
+// ImportQuery imports all of the bugs found by the search. Bugs that
+// cannot be imported are added to the failures in Counts, and only an
+// error running the search is returned.
+func (imp *Importer) ImportQuery(query url.Values) error {
+	q := url.Values{}
+	for k, v := range query {
+		q[k] = v
+	}
+	q.Set("include_fields", ImportFields)
+
+	bugs, err := imp.BugzillaClient.Search(q)
+	if err != nil {
+		return fmt.Errorf("Unable to query bugzilla: %w", err)
+	}
+
+	// One bad bug should not keep the rest from being imported, so
+	// failures are collected for the report at the end.
+	for _, bug := range bugs {
+		if _, _, err := imp.Import(bug); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			imp.Counts.Fail(imp.BugzillaClient.ShowBugURL(bug.ID), err)
+		}
+	}
+
+	return nil
+}
+
+// Import creates a ticket for the bug, unless one already exists. It
+// returns the keys of the new or existing tickets and whether a new
+// ticket was created.
+func (imp *Importer) Import(bug bugzilla.Bug) ([]string, bool, error) {
+
+	bugDisplayURL := imp.BugzillaClient.ShowBugURL(bug.ID)
+	fmt.Printf("%s \"%s\"", bugDisplayURL, bug.Summary)
+
+	slug := Slug(bug.ID)
+
+	jiraIssues, err := imp.Index.Find(slug, []string{"story", "bug", imp.Target.IssueTypeName})
+	if err != nil {
+		fmt.Printf("\n")
+		return nil, false, fmt.Errorf("Failed to search for issue: %v", err)
+	}
+
+	if len(jiraIssues) != 0 {
+		keys := []string{}
+		for _, jiraIssue := range jiraIssues {
+			fmt.Printf(" EXISTING %s %s/browse/%s\n",
+				jiraIssue.Fields.Type.Name,
+				imp.JiraURL,
+				jiraIssue.Key,
+			)
+			keys = append(keys, jiraIssue.Key)
+			if imp.StatusMap != nil {
+				imp.syncStatus(bug, jiraIssue)
+			}
+		}
+		imp.Counts.Record(false)
+		return keys, false, nil
+	}
+
+	body, attachments := markup.BugzillaToJira(bug.Description, markup.BugzillaOptions{
+		BugzillaURL:      imp.BugzillaClient.URL(),
+		AttachmentPrefix: fmt.Sprintf("bugzilla-%d", bug.ID),
+	})
+	item := Item(imp.BugzillaClient, bug)
+	item.Body = body
+
+	summary, err := imp.Templates.Summary(item)
+	if err != nil {
+		fmt.Printf("\n")
+		return nil, false, fmt.Errorf("Failed to create issue: %s", err)
+	}
+	description, err := imp.Templates.Description(item)
+	if err != nil {
+		fmt.Printf("\n")
+		return nil, false, fmt.Errorf("Failed to create issue: %s", err)
+	}
+
+	issueParams := &jira.Issue{
+		Fields: &jira.IssueFields{
+			Labels:      []string{"bugzilla"},
+			Summary:     summary,
+			Description: description,
+		},
+	}
+	if err := imp.Target.Apply(issueParams.Fields, item); err != nil {
+		fmt.Printf("\n")
+		return nil, false, fmt.Errorf("Failed to create issue: %s", err)
+	}
+	newJiraIssue, err := imp.Jira.CreateIssue(issueParams)
+	if err != nil {
+		fmt.Printf("\n")
+		return nil, false, fmt.Errorf("Failed to create issue: %s", err)
+	}
+	fmt.Printf(" CREATED %s %s/browse/%s %s\n",
+		newJiraIssue.Key,
+		imp.JiraURL,
+		newJiraIssue.Key,
+		summary,
+	)
+	imp.Index.Add(slug, jira.Issue{
+		ID:     newJiraIssue.ID,
+		Key:    newJiraIssue.Key,
+		Fields: issueParams.Fields,
+	})
+	imp.Counts.Record(true)
+
+	// Logs too long for the description are referenced from it, so
+	// attach them before doing anything else.
+	for _, attachment := range attachments {
+		err := imp.Jira.AddAttachment(newJiraIssue.ID, attachment.Name,
+			strings.NewReader(attachment.Content))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Could not attach %s: %s\n", attachment.Name, err)
+		}
+	}
+
+	imp.Watchers.Apply(imp.Jira, newJiraIssue, issueParams.Fields.Components, nil)
+
+	return []string{newJiraIssue.Key}, true, nil
+}
+
+// Item describes the bug for the templates.
+func Item(client *bugzilla.Client, bug bugzilla.Bug) *render.Item {
+	return &render.Item{
+		Source:   "bugzilla",
+		Slug:     Slug(bug.ID),
+		URL:      client.ShowBugURL(bug.ID),
+		Title:    bug.Summary,
+		Number:   bug.ID,
+		Author:   bug.Creator,
+		Created:  bug.CreationTime,
+		Labels:   []string{},
+		Product:  bug.Product,
+		Status:   bug.Status,
+		Severity: bug.Severity,
+		Priority: bug.Priority,
+	}
+}
@@ -0,0 +1,142 @@
+package bugzillaissue
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	"gopkg.in/yaml.v2"
+
+	"github.com/openshift-metal3/jira-sync/pkg/bugzilla"
+)
+
+// statusMapping connects one bugzilla status to the jira workflow
+// transition that should be used when a bug reaches that status, and
+// the jira status the ticket ends up in afterwards.
+type statusMapping struct {
+	Bugzilla   string `yaml:"bugzilla"`
+	Transition string `yaml:"transition"`
+	Status     string `yaml:"status"`
+}
+
+// StatusMap is an ordered list of mappings. The order of the entries
+// defines the direction of the workflow, so that tickets are only
+// ever moved forward.
+type StatusMap []statusMapping
+
+// LoadStatusMap reads a YAML list of mappings from bugzilla statuses
+// to jira transitions, in workflow order.
+func LoadStatusMap(filename string) (StatusMap, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	result := StatusMap{}
+	err = yaml.Unmarshal(content, &result)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse status map %s: %s", filename, err)
+	}
+
+	if len(result) == 0 {
+		return nil, fmt.Errorf("No status mappings found in %s", filename)
+	}
+	for i, m := range result {
+		if m.Bugzilla == "" || m.Transition == "" || m.Status == "" {
+			return nil, fmt.Errorf(
+				"Entry %d in %s needs bugzilla, transition, and status values", i+1, filename)
+		}
+	}
+
+	return result, nil
+}
+
+// bugzillaIndex returns the position of the bugzilla status in the
+// workflow, or -1 if the status is not mapped.
+func (m StatusMap) bugzillaIndex(status string) int {
+	for i, entry := range m {
+		if strings.EqualFold(entry.Bugzilla, status) {
+			return i
+		}
+	}
+	return -1
+}
+
+// jiraIndex returns the furthest position in the workflow that leaves
+// a ticket in the jira status, or -1 if the status is not mapped
+// (usually because the ticket is still in its initial state).
+func (m StatusMap) jiraIndex(status string) int {
+	result := -1
+	for i, entry := range m {
+		if strings.EqualFold(entry.Status, status) {
+			result = i
+		}
+	}
+	return result
+}
+
+// steps returns the mappings a ticket in the jira status has to go
+// through, in order, to reach the bugzilla status.
+func (m StatusMap) steps(jiraStatus, bugzillaStatus string) []statusMapping {
+	target := m.bugzillaIndex(bugzillaStatus)
+	current := m.jiraIndex(jiraStatus)
+	if target <= current {
+		return nil
+	}
+	return m[current+1 : target+1]
+}
+
+// syncStatus moves the jira ticket forward through its workflow to
+// match the status of the bug, one transition at a time, and stops at
+// the first transition the workflow does not allow. Problems with the
+// transitions are reported, but do not stop the caller from processing
+// other bugs.
+func (imp *Importer) syncStatus(bug bugzilla.Bug, jiraIssue jira.Issue) {
+	currentStatus := ""
+	if jiraIssue.Fields.Status != nil {
+		currentStatus = jiraIssue.Fields.Status.Name
+	}
+
+	for _, mapping := range imp.StatusMap.steps(currentStatus, bug.Status) {
+		transitions, _, err := imp.Jira.Client().Issue.GetTransitions(jiraIssue.Key)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not get transitions for %s: %s\n", jiraIssue.Key, err)
+			return
+		}
+
+		var transition *jira.Transition
+		available := []string{}
+		for i, t := range transitions {
+			if strings.EqualFold(t.Name, mapping.Transition) {
+				transition = &transitions[i]
+				break
+			}
+			available = append(available, fmt.Sprintf("%q", t.Name))
+		}
+		if transition == nil {
+			fmt.Printf("  NOT TRANSITIONED %s: %q is not allowed from %q for bug status %s (allowed: %s)\n",
+				jiraIssue.Key,
+				mapping.Transition,
+				currentStatus,
+				bug.Status,
+				strings.Join(available, ", "),
+			)
+			return
+		}
+
+		if err := imp.Jira.DoTransition(jiraIssue.Key, transition.ID); err != nil {
+			fmt.Printf("  NOT TRANSITIONED %s: %q failed: %s\n",
+				jiraIssue.Key, mapping.Transition, err)
+			return
+		}
+		fmt.Printf("  TRANSITIONED %s from %q to %q for bug status %s\n",
+			jiraIssue.Key,
+			currentStatus,
+			transition.To.Name,
+			bug.Status,
+		)
+		currentStatus = transition.To.Name
+	}
+}
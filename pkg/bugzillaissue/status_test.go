@@ -0,0 +1,92 @@
+package bugzillaissue
+
+import (
+	"reflect"
+	"testing"
+)
+
+var testStatusMap = StatusMap{
+	{Bugzilla: "ASSIGNED", Transition: "Start Progress", Status: "In Progress"},
+	{Bugzilla: "POST", Transition: "Code Review", Status: "Code Review"},
+	{Bugzilla: "MODIFIED", Transition: "Merged", Status: "Code Review"},
+	{Bugzilla: "VERIFIED", Transition: "Done", Status: "Done"},
+}
+
+func TestIndexes(t *testing.T) {
+	tests := []struct {
+		name   string
+		lookup func(string) int
+		status string
+		want   int
+	}{
+		{name: "bugzilla status", lookup: testStatusMap.bugzillaIndex, status: "POST", want: 1},
+		{name: "bugzilla status ignores case", lookup: testStatusMap.bugzillaIndex, status: "verified", want: 3},
+		{name: "unmapped bugzilla status", lookup: testStatusMap.bugzillaIndex, status: "NEW", want: -1},
+		{name: "jira status", lookup: testStatusMap.jiraIndex, status: "In Progress", want: 0},
+		{name: "jira status ignores case", lookup: testStatusMap.jiraIndex, status: "done", want: 3},
+		{name: "jira status used twice is the furthest", lookup: testStatusMap.jiraIndex, status: "Code Review", want: 2},
+		{name: "initial jira status", lookup: testStatusMap.jiraIndex, status: "To Do", want: -1},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.lookup(tc.status); got != tc.want {
+				t.Errorf("index of %q = %d, want %d", tc.status, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSteps(t *testing.T) {
+	tests := []struct {
+		name           string
+		jiraStatus     string
+		bugzillaStatus string
+		want           []string
+	}{
+		{
+			name:           "from the initial status",
+			jiraStatus:     "To Do",
+			bugzillaStatus: "MODIFIED",
+			want:           []string{"Start Progress", "Code Review", "Merged"},
+		},
+		{
+			name:           "one step",
+			jiraStatus:     "In Progress",
+			bugzillaStatus: "POST",
+			want:           []string{"Code Review"},
+		},
+		{
+			name:           "from a status used twice",
+			jiraStatus:     "Code Review",
+			bugzillaStatus: "VERIFIED",
+			want:           []string{"Done"},
+		},
+		{
+			name:           "already there",
+			jiraStatus:     "Code Review",
+			bugzillaStatus: "MODIFIED",
+		},
+		{
+			name:           "never backwards",
+			jiraStatus:     "Done",
+			bugzillaStatus: "ASSIGNED",
+		},
+		{
+			name:           "unmapped bugzilla status",
+			jiraStatus:     "To Do",
+			bugzillaStatus: "NEW",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			for _, step := range testStatusMap.steps(tc.jiraStatus, tc.bugzillaStatus) {
+				got = append(got, step.Transition)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("steps from %q to %q = %q, want %q",
+					tc.jiraStatus, tc.bugzillaStatus, got, tc.want)
+			}
+		})
+	}
+}
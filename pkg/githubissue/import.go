@@ -0,0 +1,302 @@
+// Package githubissue imports github issues into jira, so that the
+// bulk and single issue commands create identical tickets.
+package githubissue
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/google/go-github/github"
+
+	"github.com/openshift-metal3/jira-sync/pkg/index"
+	"github.com/openshift-metal3/jira-sync/pkg/jirabackend"
+	"github.com/openshift-metal3/jira-sync/pkg/markup"
+	"github.com/openshift-metal3/jira-sync/pkg/render"
+	"github.com/openshift-metal3/jira-sync/pkg/routing"
+	"github.com/openshift-metal3/jira-sync/pkg/stats"
+	"github.com/openshift-metal3/jira-sync/pkg/target"
+	"github.com/openshift-metal3/jira-sync/pkg/usermap"
+	"github.com/openshift-metal3/jira-sync/pkg/versionmap"
+	"github.com/openshift-metal3/jira-sync/pkg/watchers"
+)
+
+// Importer creates jira tickets for github issues.
+type Importer struct {
+	JiraURL string
+	Jira    jirabackend.Backend
+	Target  *target.Target
+
+	// Index finds the tickets already imported. It may be shared
+	// between importers.
+	Index *index.Index
+
+	// Users maps github logins to jira users for the reporter,
+	// assignee, and mentions. It may be nil.
+	Users *usermap.Map
+
+	// Routes choose the epic or parent of new tickets. It may be
+	// nil.
+	Routes *routing.Rules
+
+	// Versions maps github milestones to jira fix versions. It may
+	// be nil.
+	Versions *versionmap.Map
+
+	// Watchers chooses who watches new tickets. It may be nil.
+	Watchers *watchers.Policy
+
+	// Templates build the text of new tickets. It may be nil.
+	Templates *render.Templates
+
+	// Update turns on updating existing tickets to match changes in
+	// the github issue.
+	Update bool
+
+	// Counts tracks the issues imported.
+	Counts stats.Counts
+}
+
+// Slug builds a unique string to use as a search term to find jira
+// tickets based on the github ticket.
+func Slug(org, repo string, number int) string {
+	return fmt.Sprintf("github:%s:%s:%d", org, repo, number)
+}
+
+// Import creates a ticket for the github issue, unless one already
+// exists. It returns the keys of the new or existing tickets and
+// whether a new ticket was created.
+func (imp *Importer) Import(org, repo string, ghIssue *github.Issue) ([]string, bool, error) {
+	fmt.Printf("%s \"%s\"", *ghIssue.HTMLURL, *ghIssue.Title)
+
+	slug := Slug(org, repo, *ghIssue.Number)
+
+	jiraIssues, err := imp.Index.Find(slug, []string{"story", "bug", imp.Target.IssueTypeName})
+	if err != nil {
+		fmt.Printf("\n")
+		return nil, false, fmt.Errorf("Failed to search for issue: %v", err)
+	}
+
+	if len(jiraIssues) != 0 {
+		keys := []string{}
+		for _, jiraIssue := range jiraIssues {
+			fmt.Printf(" EXISTING %s %s/browse/%s\n",
+				jiraIssue.Fields.Type.Name,
+				imp.JiraURL,
+				jiraIssue.Key,
+			)
+			keys = append(keys, jiraIssue.Key)
+			if imp.Update {
+				imp.updateAssignee(ghIssue, jiraIssue)
+				imp.updateFixVersions(ghIssue, jiraIssue)
+			}
+		}
+		imp.Counts.Record(false)
+		return keys, false, nil
+	}
+
+	// github issues are written in markdown, which jira does not
+	// understand, so translate the body to jira's wiki markup.
+	body := ""
+	if ghIssue.Body != nil {
+		body = markup.ConvertMarkdown(*ghIssue.Body, markup.MarkdownOptions{
+			Mention: imp.Users.Mention,
+		})
+	}
+
+	item := Item(org, repo, ghIssue)
+	item.Body = body
+
+	summary, err := imp.Templates.Summary(item)
+	if err != nil {
+		fmt.Printf("\n")
+		return nil, false, fmt.Errorf("Failed to create issue: %s", err)
+	}
+	description, err := imp.Templates.Description(item)
+	if err != nil {
+		fmt.Printf("\n")
+		return nil, false, fmt.Errorf("Failed to create issue: %s", err)
+	}
+
+	issueParams := &jira.Issue{
+		Fields: &jira.IssueFields{
+			Labels:      []string{"github", fmt.Sprintf("%s/%s", org, repo)},
+			Summary:     summary,
+			Description: description,
+		},
+	}
+	if err := imp.Target.Apply(issueParams.Fields, item); err != nil {
+		fmt.Printf("\n")
+		return nil, false, fmt.Errorf("Failed to create issue: %s", err)
+	}
+	rule := imp.Routes.Match(org, repo, labelNames(ghIssue), *ghIssue.Title)
+	if rule != nil {
+		rule.Apply(imp.Target, issueParams.Fields)
+	}
+	if issueParams.Fields.Assignee == nil {
+		issueParams.Fields.Assignee = imp.assignee(ghIssue)
+	}
+	if imp.Target.AllowsReporter() && ghIssue.User != nil {
+		issueParams.Fields.Reporter = imp.Users.JiraUser(ghIssue.User.GetLogin())
+	}
+	if version, ok := imp.fixVersion(ghIssue); ok && version != "" {
+		issueParams.Fields.FixVersions = []*jira.FixVersion{
+			&jira.FixVersion{
+				Name: version,
+			},
+		}
+	}
+
+	newJiraIssue, err := imp.Jira.CreateIssue(issueParams)
+	if err != nil {
+		fmt.Printf("\n")
+		return nil, false, fmt.Errorf("Failed to create issue: %s", err)
+	}
+	fmt.Printf(" CREATED %s %s/browse/%s %s\n",
+		newJiraIssue.Key,
+		imp.JiraURL,
+		newJiraIssue.Key,
+		summary,
+	)
+	imp.Index.Add(slug, jira.Issue{
+		ID:     newJiraIssue.ID,
+		Key:    newJiraIssue.Key,
+		Fields: issueParams.Fields,
+	})
+	imp.Counts.Record(true)
+	ruleWatchers := []string{}
+	if rule != nil {
+		if rule.Routes() {
+			fmt.Printf("  ROUTED %s to %s\n", newJiraIssue.Key, rule)
+		}
+		ruleWatchers = rule.Watchers
+	}
+
+	imp.Watchers.Apply(imp.Jira, newJiraIssue, issueParams.Fields.Components, ruleWatchers)
+
+	return []string{newJiraIssue.Key}, true, nil
+}
+
+// Item describes the issue for the templates.
+func Item(org, repo string, ghIssue *github.Issue) *render.Item {
+	result := &render.Item{
+		Source:    "github",
+		Slug:      Slug(org, repo, ghIssue.GetNumber()),
+		URL:       ghIssue.GetHTMLURL(),
+		Title:     ghIssue.GetTitle(),
+		Number:    ghIssue.GetNumber(),
+		Author:    ghIssue.GetUser().GetLogin(),
+		Created:   ghIssue.GetCreatedAt(),
+		Labels:    labelNames(ghIssue),
+		Org:       org,
+		Repo:      repo,
+		Milestone: ghIssue.GetMilestone().GetTitle(),
+		Assignees: []string{},
+	}
+	for _, assignee := range ghIssue.Assignees {
+		result.Assignees = append(result.Assignees, assignee.GetLogin())
+	}
+	return result
+}
+
+func labelNames(ghIssue *github.Issue) []string {
+	names := []string{}
+	for _, label := range ghIssue.Labels {
+		names = append(names, label.GetName())
+	}
+	return names
+}
+
+// assignee returns the jira user for the first of the github
+// assignees with a mapping, or nil.
+func (imp *Importer) assignee(ghIssue *github.Issue) *jira.User {
+	assignees := ghIssue.Assignees
+	if len(assignees) == 0 && ghIssue.Assignee != nil {
+		assignees = []*github.User{ghIssue.Assignee}
+	}
+	for _, ghUser := range assignees {
+		if user := imp.Users.JiraUser(ghUser.GetLogin()); user != nil {
+			return user
+		}
+	}
+	return nil
+}
+
+// updateAssignee assigns the jira ticket to match the github issue.
+// Tickets for unassigned issues are left alone, so that someone can
+// take one on the jira side without it being undone.
+func (imp *Importer) updateAssignee(ghIssue *github.Issue, jiraIssue jira.Issue) {
+	user := imp.assignee(ghIssue)
+	if user == nil || usermap.SameUser(user, jiraIssue.Fields.Assignee) {
+		return
+	}
+	if err := imp.Jira.SetAssignee(jiraIssue.ID, user); err != nil {
+		fmt.Fprintf(os.Stderr, "Could not assign %s: %s\n", jiraIssue.Key, err)
+		return
+	}
+	fmt.Printf("  ASSIGNED %s to %s\n", jiraIssue.Key, usermap.UserName(user))
+}
+
+// fixVersion returns the jira version for the milestone of the github
+// issue, which is empty if there is no milestone or it is not mapped.
+// The second value is false if the version could not be determined,
+// in which case the ticket should be left alone.
+func (imp *Importer) fixVersion(ghIssue *github.Issue) (string, bool) {
+	if ghIssue.Milestone == nil {
+		return "", true
+	}
+	version, err := imp.Versions.Version(ghIssue.Milestone.GetTitle())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", err)
+		return "", false
+	}
+	return version, true
+}
+
+// updateFixVersions replaces the fix version that came from the old
+// milestone of the github issue with the one for the current
+// milestone. Versions that are not in the milestone map were set by
+// hand and are kept.
+func (imp *Importer) updateFixVersions(ghIssue *github.Issue, jiraIssue jira.Issue) {
+	if imp.Versions == nil {
+		return
+	}
+	version, ok := imp.fixVersion(ghIssue)
+	if !ok {
+		return
+	}
+
+	changed := false
+	found := false
+	fixVersions := []map[string]string{}
+	for _, fixVersion := range jiraIssue.Fields.FixVersions {
+		if strings.EqualFold(fixVersion.Name, version) {
+			found = true
+		} else if imp.Versions.Managed(fixVersion.Name) {
+			changed = true
+			continue
+		}
+		fixVersions = append(fixVersions, map[string]string{"name": fixVersion.Name})
+	}
+	if version != "" && !found {
+		fixVersions = append(fixVersions, map[string]string{"name": version})
+		changed = true
+	}
+	if !changed {
+		return
+	}
+
+	err := imp.Jira.UpdateIssue(jiraIssue.ID, map[string]interface{}{
+		"fixVersions": fixVersions,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not set fix version of %s: %s\n", jiraIssue.Key, err)
+		return
+	}
+	if version == "" {
+		fmt.Printf("  CLEARED FIX VERSION %s\n", jiraIssue.Key)
+		return
+	}
+	fmt.Printf("  FIX VERSION %s set to %s\n", jiraIssue.Key, version)
+}
@@ -0,0 +1,131 @@
+package githubissue
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/google/go-github/github"
+)
+
+// Source selects the github issues to import.
+type Source struct {
+	Org string
+	// Repos limits the import to the named repositories. All of the
+	// repositories in the org are used if it is empty.
+	Repos []string
+	// Label limits the import to issues with the label.
+	Label string
+	// Ignore lists repositories to skip.
+	Ignore []string
+}
+
+// ImportSource imports the open issues from the repositories of the
+// source. Repositories and issues that cannot be imported are added to
+// the failures in Counts, and only an error listing the repositories
+// of the org is returned.
+func (imp *Importer) ImportSource(client *github.Client, src Source) error {
+	if len(src.Repos) > 0 {
+		return imp.importSomeRepositories(client, src)
+	}
+	return imp.importAllRepositories(client, src)
+}
+
+func (imp *Importer) importAllRepositories(client *github.Client, src Source) error {
+	ctx := context.Background()
+
+	opts := github.RepositoryListByOrgOptions{
+		Type: "all",
+	}
+
+	for {
+		page, response, err := client.Repositories.ListByOrg(ctx, src.Org, &opts)
+		if err != nil {
+			return fmt.Errorf("Failed to list %s repositories: %w", src.Org, err)
+		}
+
+		for _, repo := range page {
+			if err = imp.importRepository(client, src, repo); err != nil {
+				imp.repositoryFailed(src.Org, repo.GetName(), err)
+			}
+		}
+
+		if response.NextPage == 0 {
+			break
+		}
+		opts.Page = response.NextPage
+	}
+	return nil
+}
+
+func (imp *Importer) importSomeRepositories(client *github.Client, src Source) error {
+	ctx := context.Background()
+
+	for _, repoName := range src.Repos {
+		repo, _, err := client.Repositories.Get(ctx, src.Org, repoName)
+		if err != nil {
+			imp.repositoryFailed(src.Org, repoName, fmt.Errorf("Could not get repository: %s", err))
+			continue
+		}
+		if err = imp.importRepository(client, src, repo); err != nil {
+			imp.repositoryFailed(src.Org, repoName, err)
+		}
+	}
+
+	return nil
+}
+
+func (imp *Importer) repositoryFailed(org, repo string, err error) {
+	fmt.Fprintf(os.Stderr, "ERROR: %s/%s: %v\n", org, repo, err)
+	imp.Counts.Failures.Add(fmt.Sprintf("%s/%s", org, repo), err)
+}
+
+func (imp *Importer) importRepository(client *github.Client, src Source, repo *github.Repository) error {
+
+	for _, toIgnore := range src.Ignore {
+		if toIgnore == *repo.Name {
+			fmt.Printf("\nIGNORING %s\n", *repo.Name)
+			return nil
+		}
+	}
+
+	opts := github.IssueListByRepoOptions{
+		State: "open",
+	}
+	if src.Label != "" {
+		opts.Labels = append(opts.Labels, src.Label)
+	}
+
+	fmt.Printf("\n%s\n", *repo.Name)
+
+	for {
+		issues, response, err := client.Issues.ListByRepo(
+			context.Background(), src.Org, *repo.Name, &opts)
+		if err != nil {
+			return fmt.Errorf("Failed to list issues: %s", err)
+		}
+
+		if len(issues) == 0 {
+			fmt.Printf("no issues\n")
+			break
+		}
+
+		for _, ghIssue := range issues {
+			if ghIssue.PullRequestLinks != nil {
+				// skip pull requests
+				continue
+			}
+			if _, _, err = imp.Import(src.Org, *repo.Name, ghIssue); err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+				imp.Counts.Fail(ghIssue.GetHTMLURL(), err)
+			}
+		}
+
+		if response.NextPage == 0 {
+			break
+		}
+		opts.Page = response.NextPage
+	}
+
+	return nil
+}
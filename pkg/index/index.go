@@ -0,0 +1,139 @@
+// Package index remembers which jira tickets have been imported from
+// which upstream tickets, so that a run importing many issues does not
+// need a jira search for each one.
+package index
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/andygrunwald/go-jira"
+
+	"github.com/openshift-metal3/jira-sync/pkg/jirabackend"
+)
+
+// slugPattern matches the slugs the importers put in the summary and
+// description of the tickets they create.
+var slugPattern = regexp.MustCompile(`\b(github:[\w.-]+:[\w.-]+:\d+|bugzilla:\d+)\b`)
+
+// Index maps slugs to the jira tickets containing them. Tickets not
+// in the index are looked up with a search, so the index only ever
+// saves work and an empty one is always correct.
+type Index struct {
+	backend jirabackend.Backend
+
+	mutex  sync.Mutex
+	bySlug map[string][]jira.Issue
+	loaded map[string]bool
+}
+
+// New creates an empty index.
+func New(backend jirabackend.Backend) *Index {
+	return &Index{
+		backend: backend,
+		bySlug:  make(map[string][]jira.Issue),
+		loaded:  make(map[string]bool),
+	}
+}
+
+// Load adds the tickets found by the JQL query to the index. Loading
+// the same query twice does nothing.
+func (idx *Index) Load(jql string) (int, error) {
+	idx.mutex.Lock()
+	done := idx.loaded[jql]
+	idx.mutex.Unlock()
+	if done {
+		return 0, nil
+	}
+
+	count := 0
+	err := idx.backend.SearchPages(jql, &jira.SearchOptions{MaxResults: 100},
+		func(issue jira.Issue) error {
+			if issue.Fields == nil {
+				return nil
+			}
+			text := issue.Fields.Summary + "\n" + issue.Fields.Description
+			for _, slug := range Slugs(text) {
+				idx.Add(slug, issue)
+			}
+			count++
+			return nil
+		})
+	if err != nil {
+		return count, fmt.Errorf("could not load tickets for %q: %s", jql, err)
+	}
+
+	idx.mutex.Lock()
+	idx.loaded[jql] = true
+	idx.mutex.Unlock()
+	return count, nil
+}
+
+// Add records that the ticket contains the slug.
+func (idx *Index) Add(slug string, issue jira.Issue) {
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+	for _, existing := range idx.bySlug[slug] {
+		if existing.Key == issue.Key {
+			return
+		}
+	}
+	idx.bySlug[slug] = append(idx.bySlug[slug], issue)
+}
+
+// Find returns the tickets with the slug and one of the issue types.
+// If the index does not know of any, jira is searched and the results
+// are remembered.
+func (idx *Index) Find(slug string, issueTypes []string) ([]jira.Issue, error) {
+	idx.mutex.Lock()
+	known := filterTypes(idx.bySlug[slug], issueTypes)
+	idx.mutex.Unlock()
+	if len(known) != 0 {
+		return known, nil
+	}
+
+	quoted := make([]string, len(issueTypes))
+	for i, name := range issueTypes {
+		quoted[i] = fmt.Sprintf("type = \"%s\"", name)
+	}
+	search := fmt.Sprintf("text ~ \"%s\" and ( %s )", slug, strings.Join(quoted, " or "))
+	found, err := idx.backend.Search(search, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, issue := range found {
+		idx.Add(slug, issue)
+	}
+	return found, nil
+}
+
+func filterTypes(issues []jira.Issue, issueTypes []string) []jira.Issue {
+	results := []jira.Issue{}
+	for _, issue := range issues {
+		if issue.Fields == nil {
+			continue
+		}
+		for _, name := range issueTypes {
+			if strings.EqualFold(issue.Fields.Type.Name, name) {
+				results = append(results, issue)
+				break
+			}
+		}
+	}
+	return results
+}
+
+// Slugs returns the slugs in the text, without duplicates.
+func Slugs(text string) []string {
+	seen := make(map[string]bool)
+	results := []string{}
+	for _, slug := range slugPattern.FindAllString(text, -1) {
+		if !seen[slug] {
+			seen[slug] = true
+			results = append(results, slug)
+		}
+	}
+	return results
+}
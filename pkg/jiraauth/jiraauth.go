@@ -0,0 +1,108 @@
+// Package jiraauth builds the HTTP clients used to talk to jira with
+// each of the ways jira servers accept credentials.
+package jiraauth
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Mode selects how credentials are passed to jira.
+type Mode string
+
+const (
+	// Basic sends the username and password with HTTP basic auth.
+	Basic Mode = "basic"
+	// Token sends a personal access token as a bearer token, for
+	// servers that do not accept passwords.
+	Token Mode = "token"
+	// Cloud sends the account email address and an API token with
+	// HTTP basic auth, as Jira Cloud expects.
+	Cloud Mode = "cloud"
+	// Session logs in with the username and password once and sends
+	// the session cookie with each request.
+	Session Mode = "session"
+)
+
+// Credentials holds the settings for authenticating with jira.
+type Credentials struct {
+	Mode     Mode
+	User     string
+	Password string
+	Token    string
+}
+
+// Validate ensures the values needed by the auth mode are present.
+func (c Credentials) Validate() error {
+	switch c.Mode {
+	case Basic, Session:
+		if c.User == "" || c.Password == "" {
+			return fmt.Errorf("jira auth mode %q requires a user and password", c.Mode)
+		}
+	case Token:
+		if c.Token == "" {
+			return fmt.Errorf("jira auth mode %q requires a personal access token", c.Mode)
+		}
+	case Cloud:
+		if c.User == "" || c.Token == "" {
+			return fmt.Errorf("jira auth mode %q requires an email address and API token", c.Mode)
+		}
+	default:
+		return fmt.Errorf("unknown jira auth mode %q, use one of basic, token, cloud, or session", c.Mode)
+	}
+	return nil
+}
+
+// Client returns an HTTP client that adds the credentials to the
+// requests it sends to the server at baseURL. Requests go through
+// base, or the default transport if base is nil.
+func (c Credentials) Client(baseURL string, base http.RoundTripper) (*http.Client, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	var transport http.RoundTripper
+	switch c.Mode {
+	case Basic:
+		transport = &basicTransport{user: c.User, password: c.Password, base: base}
+	case Cloud:
+		transport = &basicTransport{user: c.User, password: c.Token, base: base}
+	case Token:
+		transport = &bearerTransport{token: c.Token, base: base}
+	case Session:
+		transport = &sessionTransport{
+			loginURL: strings.TrimSuffix(baseURL, "/") + "/rest/auth/1/session",
+			user:     c.User,
+			password: c.Password,
+			base:     base,
+		}
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+type basicTransport struct {
+	user     string
+	password string
+	base     http.RoundTripper
+}
+
+func (t *basicTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req2 := req.Clone(req.Context())
+	req2.SetBasicAuth(t.user, t.password)
+	return t.base.RoundTrip(req2)
+}
+
+type bearerTransport struct {
+	token string
+	base  http.RoundTripper
+}
+
+func (t *bearerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req2 := req.Clone(req.Context())
+	req2.Header.Set("Authorization", "Bearer "+t.token)
+	return t.base.RoundTrip(req2)
+}
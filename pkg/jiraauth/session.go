@@ -0,0 +1,116 @@
+package jiraauth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// sessionTransport logs in to get a session cookie the first time it
+// is used, and again if the server stops accepting the session.
+type sessionTransport struct {
+	loginURL string
+	user     string
+	password string
+	base     http.RoundTripper
+
+	mutex      sync.Mutex
+	cookies    []*http.Cookie
+	generation int
+}
+
+func (t *sessionTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	cookies, generation, err := t.session(0)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := t.send(req, cookies, false)
+	if err != nil || res.StatusCode != http.StatusUnauthorized {
+		return res, err
+	}
+
+	// The session may have expired. Log in again and retry once, if
+	// the body of the request can be sent a second time.
+	if req.Body != nil && req.GetBody == nil {
+		return res, nil
+	}
+	res.Body.Close()
+	cookies, _, err = t.session(generation)
+	if err != nil {
+		return nil, err
+	}
+	return t.send(req, cookies, true)
+}
+
+func (t *sessionTransport) send(req *http.Request, cookies []*http.Cookie, retry bool) (*http.Response, error) {
+	req2 := req.Clone(req.Context())
+	if retry && req.Body != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		req2.Body = body
+	}
+	for _, cookie := range cookies {
+		if cookie.Value != "" {
+			req2.AddCookie(cookie)
+		}
+	}
+	return t.base.RoundTrip(req2)
+}
+
+// session returns the current session cookies, logging in if there
+// are none or if the caller found the ones from the given generation
+// to have expired and no one has replaced them yet.
+func (t *sessionTransport) session(expired int) ([]*http.Cookie, int, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.cookies != nil && t.generation != expired {
+		return t.cookies, t.generation, nil
+	}
+
+	cookies, err := t.login()
+	if err != nil {
+		return nil, 0, err
+	}
+	t.cookies = cookies
+	t.generation++
+	return t.cookies, t.generation, nil
+}
+
+func (t *sessionTransport) login() ([]*http.Cookie, error) {
+	body, err := json.Marshal(map[string]string{
+		"username": t.user,
+		"password": t.password,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, t.loginURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to build jira login request: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to log in to jira: %s", err)
+	}
+	defer res.Body.Close()
+	ioutil.ReadAll(res.Body)
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Unable to log in to jira as %s: %s", t.user, res.Status)
+	}
+	cookies := res.Cookies()
+	if len(cookies) == 0 {
+		return nil, fmt.Errorf("Unable to log in to jira as %s: no session cookie in the response", t.user)
+	}
+	return cookies, nil
+}
@@ -0,0 +1,151 @@
+// Package jirabackend hides the differences between the REST APIs of
+// Jira Server or Data Center and Jira Cloud from the commands. Text
+// going in and coming out is always jira wiki markup, and users are
+// named by username on Server and by accountId on Cloud.
+package jirabackend
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// Backend is the part of the jira API that differs between Server
+// and Cloud, along with every call that changes anything so that a
+// dry run can replace them. Reads that work the same on both use the
+// client directly.
+type Backend interface {
+	// Client returns the client the backend uses.
+	Client() *jira.Client
+
+	// CreateIssue creates a ticket. The description is wiki markup.
+	CreateIssue(issue *jira.Issue) (*jira.Issue, error)
+
+	// GetIssue fetches a ticket, with the description and comments
+	// as wiki markup.
+	GetIssue(key string, options *jira.GetQueryOptions) (*jira.Issue, error)
+
+	// Search returns one page of the tickets matching the JQL.
+	Search(jql string, options *jira.SearchOptions) ([]jira.Issue, error)
+
+	// SearchPages calls f for every ticket matching the JQL.
+	SearchPages(jql string, options *jira.SearchOptions, f func(jira.Issue) error) error
+
+	// AddComment adds a comment written in wiki markup and returns
+	// its ID.
+	AddComment(issueID, body string) (string, error)
+
+	// Myself returns the user the backend is logged in as.
+	Myself() (*jira.User, error)
+
+	// FindUser looks up a user given by username or account ID, and
+	// returns the user as the backend needs it to set fields.
+	FindUser(user *jira.User) (*jira.User, error)
+
+	// AddWatcher and RemoveWatcher change the watchers of a ticket.
+	AddWatcher(issueID string, user *jira.User) error
+	RemoveWatcher(issueID string, user *jira.User) error
+
+	// UpdateIssue sets fields of a ticket. Rich text fields are not
+	// converted, so leave them out.
+	UpdateIssue(issueID string, fields map[string]interface{}) error
+
+	// SetAssignee assigns a ticket to the user.
+	SetAssignee(issueID string, user *jira.User) error
+
+	// DoTransition moves a ticket through the workflow.
+	DoTransition(issueID, transitionID string) error
+
+	// AddAttachment attaches a file to a ticket.
+	AddAttachment(issueID, name string, content io.Reader) error
+
+	// CreateVersion creates a version in a project.
+	CreateVersion(version *jira.Version) (*jira.Version, error)
+
+	// DeleteIssue deletes a ticket, along with its sub-tasks.
+	DeleteIssue(issueID string) error
+
+	// DeleteComment deletes a comment from a ticket.
+	DeleteComment(issueID, commentID string) error
+}
+
+// New returns the Cloud backend if cloud is true, and the Server
+// backend otherwise. For Server, user is the name the client logs in
+// with, if known.
+func New(client *jira.Client, cloud bool, user string) Backend {
+	if cloud {
+		return &cloudBackend{client: client}
+	}
+	return &serverBackend{client: client, user: user}
+}
+
+// do sends the request and returns an error with the messages from
+// the body of a failed response.
+func do(client *jira.Client, method, endpoint string, body, v interface{}) error {
+	req, err := client.NewRequest(method, endpoint, body)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req, v)
+	if err != nil {
+		return jira.NewJiraError(resp, err)
+	}
+	return nil
+}
+
+func updateIssue(client *jira.Client, version, issueID string, fields map[string]interface{}) error {
+	body := map[string]interface{}{
+		"fields": fields,
+	}
+	return do(client, "PUT", fmt.Sprintf("rest/api/%s/issue/%s", version, issueID), body, nil)
+}
+
+func setAssignee(client *jira.Client, version, issueID string, user *jira.User) error {
+	return do(client, "PUT", fmt.Sprintf("rest/api/%s/issue/%s/assignee", version, issueID), user, nil)
+}
+
+func doTransition(client *jira.Client, version, issueID, transitionID string) error {
+	body := map[string]interface{}{
+		"transition": map[string]string{
+			"id": transitionID,
+		},
+	}
+	return do(client, "POST", fmt.Sprintf("rest/api/%s/issue/%s/transitions", version, issueID), body, nil)
+}
+
+func deleteIssue(client *jira.Client, version, issueID string) error {
+	return do(client, "DELETE", fmt.Sprintf("rest/api/%s/issue/%s?deleteSubtasks=true", version, issueID), nil, nil)
+}
+
+func deleteComment(client *jira.Client, version, issueID, commentID string) error {
+	return do(client, "DELETE", fmt.Sprintf("rest/api/%s/issue/%s/comment/%s", version, issueID, commentID), nil, nil)
+}
+
+// The attachment and version calls are the same on both, and the
+// client library builds them properly.
+
+func addAttachment(client *jira.Client, issueID, name string, content io.Reader) error {
+	_, resp, err := client.Issue.PostAttachment(issueID, content, name)
+	if err != nil {
+		return jira.NewJiraError(resp, err)
+	}
+	return nil
+}
+
+func createVersion(client *jira.Client, version *jira.Version) (*jira.Version, error) {
+	created, resp, err := client.Version.Create(version)
+	if err != nil {
+		return nil, jira.NewJiraError(resp, err)
+	}
+	return created, nil
+}
+
+func watchersEndpoint(version, issueID, param, user string) string {
+	endpoint := fmt.Sprintf("rest/api/%s/issue/%s/watchers", version, issueID)
+	if param != "" {
+		endpoint += "?" + param + "=" + url.QueryEscape(user)
+	}
+	return endpoint
+}
@@ -0,0 +1,317 @@
+package jirabackend
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andygrunwald/go-jira"
+
+	"github.com/openshift-metal3/jira-sync/pkg/markup"
+)
+
+// cloudBackend uses version 3 of the API, which takes Atlassian
+// Document Format for rich text and accountIds for users.
+type cloudBackend struct {
+	client *jira.Client
+
+	mutex  sync.Mutex
+	myself *jira.User
+}
+
+func (c *cloudBackend) Client() *jira.Client {
+	return c.client
+}
+
+func (c *cloudBackend) CreateIssue(issue *jira.Issue) (*jira.Issue, error) {
+	// Let the client library encode the fields, including any custom
+	// fields, then swap in the ADF description.
+	data, err := json.Marshal(issue)
+	if err != nil {
+		return nil, err
+	}
+	body := map[string]interface{}{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, err
+	}
+	if fields, ok := body["fields"].(map[string]interface{}); ok {
+		delete(fields, "description")
+		if issue.Fields.Description != "" {
+			fields["description"] = markup.JiraToADF(issue.Fields.Description)
+		}
+	}
+
+	created := &jira.Issue{}
+	if err := do(c.client, "POST", "rest/api/3/issue", body, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (c *cloudBackend) GetIssue(key string, options *jira.GetQueryOptions) (*jira.Issue, error) {
+	q := url.Values{}
+	if options != nil {
+		setParam(q, "fields", options.Fields)
+		setParam(q, "expand", options.Expand)
+		setParam(q, "properties", options.Properties)
+	}
+	endpoint := fmt.Sprintf("rest/api/3/issue/%s", key)
+	if len(q) > 0 {
+		endpoint += "?" + q.Encode()
+	}
+
+	raw := json.RawMessage{}
+	if err := do(c.client, "GET", endpoint, nil, &raw); err != nil {
+		return nil, err
+	}
+	return decodeIssue(raw)
+}
+
+type cloudSearchResult struct {
+	Issues     []json.RawMessage `json:"issues"`
+	StartAt    int               `json:"startAt"`
+	MaxResults int               `json:"maxResults"`
+	Total      int               `json:"total"`
+}
+
+func (c *cloudBackend) search(jql string, options *jira.SearchOptions) ([]jira.Issue, *cloudSearchResult, error) {
+	q := url.Values{}
+	q.Set("jql", jql)
+	if options != nil {
+		if options.StartAt != 0 {
+			q.Set("startAt", strconv.Itoa(options.StartAt))
+		}
+		if options.MaxResults != 0 {
+			q.Set("maxResults", strconv.Itoa(options.MaxResults))
+		}
+		setParam(q, "fields", strings.Join(options.Fields, ","))
+		setParam(q, "expand", options.Expand)
+		setParam(q, "validateQuery", options.ValidateQuery)
+	}
+
+	result := &cloudSearchResult{}
+	if err := do(c.client, "GET", "rest/api/3/search?"+q.Encode(), nil, result); err != nil {
+		return nil, nil, err
+	}
+	issues := make([]jira.Issue, 0, len(result.Issues))
+	for _, raw := range result.Issues {
+		issue, err := decodeIssue(raw)
+		if err != nil {
+			return nil, nil, err
+		}
+		issues = append(issues, *issue)
+	}
+	return issues, result, nil
+}
+
+func (c *cloudBackend) Search(jql string, options *jira.SearchOptions) ([]jira.Issue, error) {
+	issues, _, err := c.search(jql, options)
+	return issues, err
+}
+
+func (c *cloudBackend) SearchPages(jql string, options *jira.SearchOptions, f func(jira.Issue) error) error {
+	page := jira.SearchOptions{MaxResults: 50}
+	if options != nil {
+		page = *options
+	}
+	for {
+		issues, result, err := c.search(jql, &page)
+		if err != nil {
+			return err
+		}
+		for _, issue := range issues {
+			if err := f(issue); err != nil {
+				return err
+			}
+		}
+		page.StartAt += len(issues)
+		if len(issues) == 0 || page.StartAt >= result.Total {
+			return nil
+		}
+	}
+}
+
+func (c *cloudBackend) AddComment(issueID, body string) (string, error) {
+	comment := map[string]interface{}{
+		"body": markup.JiraToADF(body),
+	}
+	created := struct {
+		ID string `json:"id"`
+	}{}
+	err := do(c.client, "POST", fmt.Sprintf("rest/api/3/issue/%s/comment", issueID), comment, &created)
+	return created.ID, err
+}
+
+func (c *cloudBackend) Myself() (*jira.User, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.myself != nil {
+		return c.myself, nil
+	}
+	user := &jira.User{}
+	if err := do(c.client, "GET", "rest/api/3/myself", nil, user); err != nil {
+		return nil, err
+	}
+	c.myself = user
+	return user, nil
+}
+
+// FindUser searches for users other than by account ID, since Jira
+// Cloud does not have usernames. The search must find exactly one.
+func (c *cloudBackend) FindUser(user *jira.User) (*jira.User, error) {
+	if user.AccountID != "" {
+		found := &jira.User{}
+		endpoint := "rest/api/3/user?accountId=" + url.QueryEscape(user.AccountID)
+		if err := do(c.client, "GET", endpoint, nil, found); err != nil {
+			return nil, err
+		}
+		return found, nil
+	}
+
+	users := []jira.User{}
+	endpoint := "rest/api/3/user/search?query=" + url.QueryEscape(user.Name)
+	if err := do(c.client, "GET", endpoint, nil, &users); err != nil {
+		return nil, err
+	}
+	switch len(users) {
+	case 0:
+		return nil, fmt.Errorf("no user matches %q", user.Name)
+	case 1:
+		return &users[0], nil
+	}
+	names := []string{}
+	for _, u := range users {
+		names = append(names, fmt.Sprintf("%s (accountid:%s)", u.DisplayName, u.AccountID))
+	}
+	return nil, fmt.Errorf("%d users match %q, use one of: %s", len(users), user.Name, strings.Join(names, ", "))
+}
+
+func (c *cloudBackend) AddWatcher(issueID string, user *jira.User) error {
+	if user.AccountID == "" {
+		return fmt.Errorf("watchers need an accountId on jira cloud")
+	}
+	return do(c.client, "POST", watchersEndpoint("3", issueID, "", ""), user.AccountID, nil)
+}
+
+func (c *cloudBackend) RemoveWatcher(issueID string, user *jira.User) error {
+	if user.AccountID == "" {
+		return fmt.Errorf("watchers need an accountId on jira cloud")
+	}
+	return do(c.client, "DELETE", watchersEndpoint("3", issueID, "accountId", user.AccountID), nil, nil)
+}
+
+func (c *cloudBackend) UpdateIssue(issueID string, fields map[string]interface{}) error {
+	return updateIssue(c.client, "3", issueID, fields)
+}
+
+func (c *cloudBackend) SetAssignee(issueID string, user *jira.User) error {
+	return setAssignee(c.client, "3", issueID, user)
+}
+
+func (c *cloudBackend) DoTransition(issueID, transitionID string) error {
+	return doTransition(c.client, "3", issueID, transitionID)
+}
+
+func (c *cloudBackend) AddAttachment(issueID, name string, content io.Reader) error {
+	return addAttachment(c.client, issueID, name, content)
+}
+
+func (c *cloudBackend) CreateVersion(version *jira.Version) (*jira.Version, error) {
+	return createVersion(c.client, version)
+}
+
+func (c *cloudBackend) DeleteIssue(issueID string) error {
+	return deleteIssue(c.client, "3", issueID)
+}
+
+func (c *cloudBackend) DeleteComment(issueID, commentID string) error {
+	return deleteComment(c.client, "3", issueID, commentID)
+}
+
+func setParam(q url.Values, name, value string) {
+	if value != "" {
+		q.Set(name, value)
+	}
+}
+
+// decodeIssue parses a ticket from version 3 of the API, converting
+// the rich text fields to wiki markup so they fit in jira.Issue.
+func decodeIssue(raw json.RawMessage) (*jira.Issue, error) {
+	issue := map[string]json.RawMessage{}
+	if err := json.Unmarshal(raw, &issue); err != nil {
+		return nil, err
+	}
+
+	if rawFields, ok := issue["fields"]; ok {
+		fields := map[string]json.RawMessage{}
+		if err := json.Unmarshal(rawFields, &fields); err != nil {
+			return nil, err
+		}
+		for _, name := range []string{"description", "environment"} {
+			if value, ok := fields[name]; ok {
+				fields[name] = adfToWiki(value)
+			}
+		}
+		if value, ok := fields["comment"]; ok {
+			fields["comment"] = convertComments(value)
+		}
+		data, err := json.Marshal(fields)
+		if err != nil {
+			return nil, err
+		}
+		issue["fields"] = data
+	}
+
+	data, err := json.Marshal(issue)
+	if err != nil {
+		return nil, err
+	}
+	result := &jira.Issue{}
+	if err := json.Unmarshal(data, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func convertComments(raw json.RawMessage) json.RawMessage {
+	comments := map[string]json.RawMessage{}
+	if err := json.Unmarshal(raw, &comments); err != nil {
+		return raw
+	}
+	list := []map[string]json.RawMessage{}
+	if err := json.Unmarshal(comments["comments"], &list); err != nil {
+		return raw
+	}
+	for _, comment := range list {
+		if body, ok := comment["body"]; ok {
+			comment["body"] = adfToWiki(body)
+		}
+	}
+	data, err := json.Marshal(list)
+	if err != nil {
+		return raw
+	}
+	comments["comments"] = data
+	if data, err = json.Marshal(comments); err != nil {
+		return raw
+	}
+	return data
+}
+
+// adfToWiki replaces an ADF document with a string of wiki markup.
+// Anything that is not a document is returned as is.
+func adfToWiki(raw json.RawMessage) json.RawMessage {
+	doc := &markup.ADFNode{}
+	if err := json.Unmarshal(raw, doc); err != nil || doc.Type != "doc" {
+		return raw
+	}
+	data, err := json.Marshal(markup.ADFToJira(doc))
+	if err != nil {
+		return raw
+	}
+	return data
+}
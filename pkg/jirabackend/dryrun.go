@@ -0,0 +1,209 @@
+package jirabackend
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// DryRun is a Backend that changes nothing. Reads go to the backend
+// it wraps, and each write is printed and remembered for ShowPlan
+// instead of being sent. A nil DryRun shows no plan.
+type DryRun struct {
+	backend Backend
+
+	mutex   sync.Mutex
+	created int
+	counts  map[string]int
+}
+
+// The kinds of change, in the order ShowPlan lists them.
+var changeKinds = []string{
+	"tickets to create",
+	"versions to create",
+	"comments to add",
+	"transitions",
+	"assignments",
+	"field updates",
+	"attachments",
+	"watchers to add",
+	"watchers to remove",
+	"tickets to delete",
+	"comments to delete",
+}
+
+// NewDryRun wraps the backend.
+func NewDryRun(backend Backend) *DryRun {
+	return &DryRun{
+		backend: backend,
+		counts:  make(map[string]int),
+	}
+}
+
+// record counts a change and prints it. The importers print the item
+// a ticket is created from, or commented on, without ending the line,
+// so those changes start a new one.
+func (d *DryRun) record(kind, format string, args ...interface{}) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.counts[kind]++
+	if kind == "tickets to create" || kind == "comments to add" {
+		fmt.Printf("\n")
+	}
+	fmt.Printf("  DRY RUN would %s\n", fmt.Sprintf(format, args...))
+}
+
+// ShowPlan prints the number of changes of each kind that the run
+// would have made.
+func (d *DryRun) ShowPlan() {
+	if d == nil {
+		return
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	fmt.Printf("\nDry run, nothing was changed in jira. Planned changes:\n")
+	total := 0
+	for _, kind := range changeKinds {
+		if d.counts[kind] != 0 {
+			fmt.Printf("  %5d %s\n", d.counts[kind], kind)
+			total += d.counts[kind]
+		}
+	}
+	if total == 0 {
+		fmt.Printf("  none\n")
+	}
+}
+
+func (d *DryRun) Client() *jira.Client {
+	return d.backend.Client()
+}
+
+// CreateIssue returns a ticket with a made up key, so the rest of
+// the run can refer to it.
+func (d *DryRun) CreateIssue(issue *jira.Issue) (*jira.Issue, error) {
+	d.mutex.Lock()
+	d.created++
+	key := fmt.Sprintf("%s-DRYRUN%d", issue.Fields.Project.Key, d.created)
+	d.mutex.Unlock()
+
+	details := []string{}
+	for _, component := range issue.Fields.Components {
+		details = append(details, "component "+component.Name)
+	}
+	if len(issue.Fields.Labels) != 0 {
+		details = append(details, "labels "+strings.Join(issue.Fields.Labels, ","))
+	}
+	if issue.Fields.Parent != nil {
+		details = append(details, "parent "+issue.Fields.Parent.Key)
+	}
+	if issue.Fields.Assignee != nil {
+		details = append(details, "assignee "+userName(issue.Fields.Assignee))
+	}
+	for _, version := range issue.Fields.FixVersions {
+		details = append(details, "fix version "+version.Name)
+	}
+	ids := []string{}
+	for id := range issue.Fields.Unknowns {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		details = append(details, fmt.Sprintf("%s %v", id, issue.Fields.Unknowns[id]))
+	}
+	d.record("tickets to create", "create %s %s in %s %q (%s)",
+		issue.Fields.Type.Name, key, issue.Fields.Project.Key, issue.Fields.Summary,
+		strings.Join(details, ", "))
+
+	return &jira.Issue{
+		ID:  key,
+		Key: key,
+	}, nil
+}
+
+func (d *DryRun) GetIssue(key string, options *jira.GetQueryOptions) (*jira.Issue, error) {
+	return d.backend.GetIssue(key, options)
+}
+
+func (d *DryRun) Search(jql string, options *jira.SearchOptions) ([]jira.Issue, error) {
+	return d.backend.Search(jql, options)
+}
+
+func (d *DryRun) SearchPages(jql string, options *jira.SearchOptions, f func(jira.Issue) error) error {
+	return d.backend.SearchPages(jql, options, f)
+}
+
+func (d *DryRun) AddComment(issueID, body string) (string, error) {
+	d.record("comments to add", "comment on %s: %q", issueID, body)
+	return "", nil
+}
+
+func (d *DryRun) Myself() (*jira.User, error) {
+	return d.backend.Myself()
+}
+
+func (d *DryRun) FindUser(user *jira.User) (*jira.User, error) {
+	return d.backend.FindUser(user)
+}
+
+func (d *DryRun) AddWatcher(issueID string, user *jira.User) error {
+	d.record("watchers to add", "add %s as a watcher of %s", userName(user), issueID)
+	return nil
+}
+
+func (d *DryRun) RemoveWatcher(issueID string, user *jira.User) error {
+	d.record("watchers to remove", "remove %s as a watcher of %s", userName(user), issueID)
+	return nil
+}
+
+func (d *DryRun) UpdateIssue(issueID string, fields map[string]interface{}) error {
+	d.record("field updates", "set %v on %s", fields, issueID)
+	return nil
+}
+
+func (d *DryRun) SetAssignee(issueID string, user *jira.User) error {
+	d.record("assignments", "assign %s to %s", issueID, userName(user))
+	return nil
+}
+
+func (d *DryRun) DoTransition(issueID, transitionID string) error {
+	d.record("transitions", "do transition %s on %s", transitionID, issueID)
+	return nil
+}
+
+func (d *DryRun) AddAttachment(issueID, name string, content io.Reader) error {
+	size, _ := io.Copy(ioutil.Discard, content)
+	d.record("attachments", "attach %s (%d bytes) to %s", name, size, issueID)
+	return nil
+}
+
+func (d *DryRun) CreateVersion(version *jira.Version) (*jira.Version, error) {
+	d.record("versions to create", "create version %q in project %d", version.Name, version.ProjectID)
+	created := *version
+	return &created, nil
+}
+
+func (d *DryRun) DeleteIssue(issueID string) error {
+	d.record("tickets to delete", "delete %s", issueID)
+	return nil
+}
+
+func (d *DryRun) DeleteComment(issueID, commentID string) error {
+	d.record("comments to delete", "delete comment %s from %s", commentID, issueID)
+	return nil
+}
+
+func userName(user *jira.User) string {
+	if user == nil {
+		return "nobody"
+	}
+	if user.Name != "" {
+		return user.Name
+	}
+	return "accountid:" + user.AccountID
+}
@@ -0,0 +1,127 @@
+package jirabackend
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// serverBackend uses version 2 of the API, which takes wiki markup
+// and usernames.
+type serverBackend struct {
+	client *jira.Client
+
+	mutex sync.Mutex
+	user  string
+}
+
+func (s *serverBackend) Client() *jira.Client {
+	return s.client
+}
+
+func (s *serverBackend) CreateIssue(issue *jira.Issue) (*jira.Issue, error) {
+	created, resp, err := s.client.Issue.Create(issue)
+	if err != nil {
+		return nil, jira.NewJiraError(resp, err)
+	}
+	return created, nil
+}
+
+func (s *serverBackend) GetIssue(key string, options *jira.GetQueryOptions) (*jira.Issue, error) {
+	issue, resp, err := s.client.Issue.Get(key, options)
+	if err != nil {
+		return nil, jira.NewJiraError(resp, err)
+	}
+	return issue, nil
+}
+
+func (s *serverBackend) Search(jql string, options *jira.SearchOptions) ([]jira.Issue, error) {
+	issues, resp, err := s.client.Issue.Search(jql, options)
+	if err != nil {
+		return nil, jira.NewJiraError(resp, err)
+	}
+	return issues, nil
+}
+
+func (s *serverBackend) SearchPages(jql string, options *jira.SearchOptions, f func(jira.Issue) error) error {
+	return s.client.Issue.SearchPages(jql, options, f)
+}
+
+func (s *serverBackend) AddComment(issueID, body string) (string, error) {
+	comment, resp, err := s.client.Issue.AddComment(issueID, &jira.Comment{Body: body})
+	if err != nil {
+		return "", jira.NewJiraError(resp, err)
+	}
+	return comment.ID, nil
+}
+
+func (s *serverBackend) Myself() (*jira.User, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.user != "" {
+		return &jira.User{Name: s.user}, nil
+	}
+	user, resp, err := s.client.User.GetSelf()
+	if err != nil {
+		return nil, jira.NewJiraError(resp, err)
+	}
+	s.user = user.Name
+	return user, nil
+}
+
+func (s *serverBackend) FindUser(user *jira.User) (*jira.User, error) {
+	if user.Name == "" {
+		return nil, fmt.Errorf("jira server users are found by username, not account ID %s", user.AccountID)
+	}
+	found, resp, err := s.client.User.Get(user.Name)
+	if err != nil {
+		return nil, jira.NewJiraError(resp, err)
+	}
+	return found, nil
+}
+
+func (s *serverBackend) AddWatcher(issueID string, user *jira.User) error {
+	if user.Name == "" {
+		return fmt.Errorf("watchers need a username on jira server")
+	}
+	return do(s.client, "POST", watchersEndpoint("2", issueID, "", ""), user.Name, nil)
+}
+
+func (s *serverBackend) RemoveWatcher(issueID string, user *jira.User) error {
+	if user.Name == "" {
+		return fmt.Errorf("watchers need a username on jira server")
+	}
+	// The client library does not build the remove request properly,
+	// so do it ourselves.
+	return do(s.client, "DELETE", watchersEndpoint("2", issueID, "username", user.Name), nil, nil)
+}
+
+func (s *serverBackend) UpdateIssue(issueID string, fields map[string]interface{}) error {
+	return updateIssue(s.client, "2", issueID, fields)
+}
+
+func (s *serverBackend) SetAssignee(issueID string, user *jira.User) error {
+	return setAssignee(s.client, "2", issueID, user)
+}
+
+func (s *serverBackend) DoTransition(issueID, transitionID string) error {
+	return doTransition(s.client, "2", issueID, transitionID)
+}
+
+func (s *serverBackend) AddAttachment(issueID, name string, content io.Reader) error {
+	return addAttachment(s.client, issueID, name, content)
+}
+
+func (s *serverBackend) CreateVersion(version *jira.Version) (*jira.Version, error) {
+	return createVersion(s.client, version)
+}
+
+func (s *serverBackend) DeleteIssue(issueID string) error {
+	return deleteIssue(s.client, "2", issueID)
+}
+
+func (s *serverBackend) DeleteComment(issueID, commentID string) error {
+	return deleteComment(s.client, "2", issueID, commentID)
+}
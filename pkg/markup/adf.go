@@ -0,0 +1,555 @@
+package markup
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ADFNode is a node of an Atlassian Document Format document, the
+// rich text format used by version 3 of the Jira Cloud API.
+type ADFNode struct {
+	Type    string                 `json:"type"`
+	Version int                    `json:"version,omitempty"`
+	Attrs   map[string]interface{} `json:"attrs,omitempty"`
+	Content []*ADFNode             `json:"content,omitempty"`
+	Text    string                 `json:"text,omitempty"`
+	Marks   []*ADFMark             `json:"marks,omitempty"`
+}
+
+// ADFMark is the formatting applied to a text node.
+type ADFMark struct {
+	Type  string                 `json:"type"`
+	Attrs map[string]interface{} `json:"attrs,omitempty"`
+}
+
+var (
+	wikiCodePattern    = regexp.MustCompile(`^\{code(?::([^}]*))?\}\s*$`)
+	wikiPanelPattern   = regexp.MustCompile(`^\{panel(?::title=([^}|]*))?[^}]*\}\s*$`)
+	wikiHeadingPattern = regexp.MustCompile(`^h([1-6])\.\s+(.*)$`)
+	wikiListPattern    = regexp.MustCompile(`^([*#]+)\s+(.*)$`)
+	wikiEscapePattern  = regexp.MustCompile(`\\([{}\[\]|!*_^~+\-\\])`)
+)
+
+// Private use runes stand in for escaped characters while the inline
+// markup is parsed.
+const escapeBase = 0xE000
+
+// JiraToADF converts jira wiki markup into an ADF document. It covers
+// the markup produced by the conversions in this package: headings,
+// lists, tables, code, noformat, quote, and panel blocks, rules,
+// links, mentions, and text effects.
+func JiraToADF(wiki string) *ADFNode {
+	b := &adfBuilder{}
+	doc := &ADFNode{Type: "doc", Version: 1}
+	b.stack = []*ADFNode{doc}
+
+	lines := strings.Split(strings.Replace(wiki, "\r\n", "\n", -1), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		code := wikiCodePattern.FindStringSubmatch(trimmed)
+		if code != nil || trimmed == "{noformat}" {
+			end := "{noformat}"
+			attrs := map[string]interface{}{}
+			if code != nil {
+				end = "{code}"
+				if code[1] != "" {
+					attrs["language"] = code[1]
+				}
+			}
+			body := []string{}
+			for i++; i < len(lines) && strings.TrimSpace(lines[i]) != end; i++ {
+				body = append(body, lines[i])
+			}
+			block := &ADFNode{Type: "codeBlock"}
+			if len(attrs) > 0 {
+				block.Attrs = attrs
+			}
+			if text := strings.Join(body, "\n"); text != "" {
+				block.Content = []*ADFNode{{Type: "text", Text: text}}
+			}
+			b.addBlock(block)
+			continue
+		}
+
+		if trimmed == "{quote}" {
+			if b.inside("blockquote") {
+				b.pop()
+			} else {
+				b.push(&ADFNode{Type: "blockquote"})
+			}
+			continue
+		}
+
+		if match := wikiPanelPattern.FindStringSubmatch(trimmed); match != nil {
+			if b.inside("panel") || b.inside("expand") {
+				b.pop()
+			} else if match[1] != "" {
+				b.push(&ADFNode{Type: "expand", Attrs: map[string]interface{}{"title": match[1]}})
+			} else {
+				b.push(&ADFNode{Type: "panel", Attrs: map[string]interface{}{"panelType": "info"}})
+			}
+			continue
+		}
+
+		if trimmed == "----" {
+			b.addBlock(&ADFNode{Type: "rule"})
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "bq. ") {
+			b.addBlock(&ADFNode{Type: "blockquote", Content: []*ADFNode{
+				paragraph(inlineADF(trimmed[4:])),
+			}})
+			continue
+		}
+
+		if match := wikiHeadingPattern.FindStringSubmatch(trimmed); match != nil {
+			level, _ := strconv.Atoi(match[1])
+			b.addBlock(&ADFNode{
+				Type:    "heading",
+				Attrs:   map[string]interface{}{"level": level},
+				Content: inlineADF(match[2]),
+			})
+			continue
+		}
+
+		if match := wikiListPattern.FindStringSubmatch(line); match != nil {
+			b.addListItem(match[1], inlineADF(match[2]))
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "|") {
+			b.addTableRow(trimmed)
+			continue
+		}
+
+		if trimmed == "" {
+			b.endParagraph()
+			continue
+		}
+
+		b.addText(inlineADF(line))
+	}
+	return doc
+}
+
+// adfBuilder tracks the open blocks while converting markup to ADF.
+// The stack holds the document and any quote or panel blocks, and
+// the paragraph, list, and table being filled in.
+type adfBuilder struct {
+	stack     []*ADFNode
+	paragraph *ADFNode
+	lists     []*ADFNode
+	table     *ADFNode
+}
+
+func (b *adfBuilder) top() *ADFNode {
+	return b.stack[len(b.stack)-1]
+}
+
+func (b *adfBuilder) inside(nodeType string) bool {
+	return len(b.stack) > 1 && b.top().Type == nodeType
+}
+
+func (b *adfBuilder) push(node *ADFNode) {
+	b.addBlock(node)
+	b.stack = append(b.stack, node)
+}
+
+func (b *adfBuilder) pop() {
+	b.endParagraph()
+	top := b.top()
+	if len(top.Content) == 0 {
+		top.Content = []*ADFNode{paragraph(nil)}
+	}
+	b.stack = b.stack[:len(b.stack)-1]
+}
+
+// endParagraph closes the open paragraph, list, or table.
+func (b *adfBuilder) endParagraph() {
+	b.paragraph = nil
+	b.lists = nil
+	b.table = nil
+}
+
+func (b *adfBuilder) addBlock(node *ADFNode) {
+	b.endParagraph()
+	top := b.top()
+	top.Content = append(top.Content, node)
+}
+
+func (b *adfBuilder) addText(content []*ADFNode) {
+	if b.paragraph != nil {
+		b.paragraph.Content = append(b.paragraph.Content, &ADFNode{Type: "hardBreak"})
+		b.paragraph.Content = append(b.paragraph.Content, content...)
+		return
+	}
+	b.addBlock(paragraph(content))
+	top := b.top()
+	b.paragraph = top.Content[len(top.Content)-1]
+}
+
+// addListItem adds an item to the list at the depth given by the
+// markers, creating and closing nested lists as needed.
+func (b *adfBuilder) addListItem(markers string, content []*ADFNode) {
+	lists := b.lists
+	if len(lists) == 0 {
+		b.endParagraph()
+	}
+	if len(lists) > len(markers) {
+		lists = lists[:len(markers)]
+	}
+	for depth := 0; depth < len(markers); depth++ {
+		listType := "bulletList"
+		if markers[depth] == '#' {
+			listType = "orderedList"
+		}
+		if depth < len(lists) && lists[depth].Type == listType {
+			continue
+		}
+		lists = lists[:depth]
+		list := &ADFNode{Type: listType}
+		if depth == 0 {
+			top := b.top()
+			top.Content = append(top.Content, list)
+		} else {
+			parent := lists[depth-1]
+			if len(parent.Content) == 0 {
+				parent.Content = []*ADFNode{{Type: "listItem", Content: []*ADFNode{paragraph(nil)}}}
+			}
+			item := parent.Content[len(parent.Content)-1]
+			item.Content = append(item.Content, list)
+		}
+		lists = append(lists, list)
+	}
+	list := lists[len(lists)-1]
+	list.Content = append(list.Content, &ADFNode{
+		Type:    "listItem",
+		Content: []*ADFNode{paragraph(content)},
+	})
+	b.paragraph = nil
+	b.table = nil
+	b.lists = lists
+}
+
+func (b *adfBuilder) addTableRow(line string) {
+	if b.table == nil {
+		b.addBlock(&ADFNode{Type: "table"})
+		top := b.top()
+		b.table = top.Content[len(top.Content)-1]
+	}
+	cellType := "tableCell"
+	separator := "|"
+	if strings.HasPrefix(line, "||") {
+		cellType = "tableHeader"
+		separator = "||"
+	}
+	line = strings.TrimSuffix(strings.TrimPrefix(line, separator), separator)
+	row := &ADFNode{Type: "tableRow"}
+	for _, cell := range splitCells(line, separator) {
+		row.Content = append(row.Content, &ADFNode{
+			Type:    cellType,
+			Content: []*ADFNode{paragraph(inlineADF(strings.TrimSpace(cell)))},
+		})
+	}
+	b.table.Content = append(b.table.Content, row)
+}
+
+// splitCells splits a table row on the separator, skipping the pipes
+// inside of links.
+func splitCells(line, separator string) []string {
+	cells := []string{}
+	depth := 0
+	start := 0
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\':
+			i++
+		case line[i] == '[':
+			depth++
+		case line[i] == ']' && depth > 0:
+			depth--
+		case depth == 0 && strings.HasPrefix(line[i:], separator):
+			cells = append(cells, line[start:i])
+			i += len(separator) - 1
+			start = i + 1
+		}
+	}
+	return append(cells, line[start:])
+}
+
+func paragraph(content []*ADFNode) *ADFNode {
+	return &ADFNode{Type: "paragraph", Content: content}
+}
+
+// inlinePattern recognizes one kind of inline markup. Group 1 of the
+// expression is the text the markup replaces, any leading context
+// outside of it is left alone.
+type inlinePattern struct {
+	kind string
+	re   *regexp.Regexp
+}
+
+var inlinePatterns = []inlinePattern{
+	{"code", regexp.MustCompile(`(\{\{(.+?)\}\})`)},
+	{"mention", regexp.MustCompile(`(\[~([^\]]+)\])`)},
+	{"attachment", regexp.MustCompile(`(\[\^([^\]]+)\])`)},
+	{"link", regexp.MustCompile(`(\[([^\]|]+)\|([^\]\s]+)\])`)},
+	{"url", regexp.MustCompile(`(\[((?:https?|ftp|mailto|file):[^\]\s|]+)\])`)},
+	{"image", regexp.MustCompile(`(!([^!\s|]+\.[^!\s|]+)(?:\|[^!]*)?!)`)},
+	{"emoji", regexp.MustCompile(`(\((x|/)\))`)},
+	{"strong", regexp.MustCompile(`(?:^|[^\w*])(\*(\S(?:[^*]*?\S)?)\*)(?:[^\w*]|$)`)},
+	{"em", regexp.MustCompile(`(?:^|[^\w_])(_(\S(?:[^_]*?\S)?)_)(?:[^\w_]|$)`)},
+	{"strike", regexp.MustCompile(`(?:^|[^\w-])(-(\S(?:[^-]*?\S)?)-)(?:[^\w-]|$)`)},
+}
+
+var emoji = map[string][2]string{
+	"x": {":cross_mark:", "❌"},
+	"/": {":check_mark:", "✅"},
+}
+
+// inlineADF converts one line of markup into ADF inline nodes.
+func inlineADF(text string) []*ADFNode {
+	text = wikiEscapePattern.ReplaceAllStringFunc(text, func(m string) string {
+		return string(rune(escapeBase + int(m[1])))
+	})
+	return convertInlineADF(text, nil)
+}
+
+func convertInlineADF(text string, marks []*ADFMark) []*ADFNode {
+	nodes := []*ADFNode{}
+	for text != "" {
+		var best inlinePattern
+		var loc []int
+		for _, p := range inlinePatterns {
+			if l := p.re.FindStringSubmatchIndex(text); l != nil && (loc == nil || l[2] < loc[2]) {
+				best, loc = p, l
+			}
+		}
+		if loc == nil {
+			break
+		}
+
+		nodes = appendText(nodes, text[:loc[2]], marks)
+		group := func(n int) string {
+			return text[loc[2*n]:loc[2*n+1]]
+		}
+
+		switch best.kind {
+		case "code":
+			nodes = appendText(nodes, group(2), []*ADFMark{{Type: "code"}})
+		case "mention":
+			if id := group(2); strings.HasPrefix(id, "accountid:") {
+				nodes = append(nodes, &ADFNode{Type: "mention", Attrs: map[string]interface{}{
+					"id": strings.TrimPrefix(id, "accountid:"),
+				}})
+			} else {
+				nodes = appendText(nodes, "@"+id, marks)
+			}
+		case "attachment":
+			nodes = appendText(nodes, group(2), marks)
+		case "link":
+			nodes = append(nodes, convertInlineADF(group(2), withMark(marks, linkMark(group(3))))...)
+		case "url", "image":
+			nodes = appendText(nodes, group(2), withMark(marks, linkMark(group(2))))
+		case "emoji":
+			e := emoji[group(2)]
+			nodes = append(nodes, &ADFNode{Type: "emoji", Attrs: map[string]interface{}{
+				"shortName": e[0],
+				"text":      e[1],
+			}})
+		default:
+			nodes = append(nodes, convertInlineADF(group(2), withMark(marks, &ADFMark{Type: best.kind}))...)
+		}
+		text = text[loc[3]:]
+	}
+	return appendText(nodes, text, marks)
+}
+
+func appendText(nodes []*ADFNode, text string, marks []*ADFMark) []*ADFNode {
+	text = strings.Map(func(r rune) rune {
+		if r >= escapeBase && r < escapeBase+128 {
+			return r - escapeBase
+		}
+		return r
+	}, text)
+	if text == "" {
+		return nodes
+	}
+	node := &ADFNode{Type: "text", Text: text}
+	if len(marks) > 0 {
+		node.Marks = marks
+	}
+	return append(nodes, node)
+}
+
+func withMark(marks []*ADFMark, mark *ADFMark) []*ADFMark {
+	result := make([]*ADFMark, 0, len(marks)+1)
+	result = append(result, marks...)
+	return append(result, mark)
+}
+
+func linkMark(href string) *ADFMark {
+	return &ADFMark{Type: "link", Attrs: map[string]interface{}{"href": href}}
+}
+
+// ADFToJira converts an ADF document into jira wiki markup, so text
+// read from Jira Cloud can be handled the same way as text from other
+// servers.
+func ADFToJira(node *ADFNode) string {
+	if node == nil {
+		return ""
+	}
+	blocks := []string{}
+	for _, child := range node.Content {
+		if text := adfBlock(child, ""); text != "" {
+			blocks = append(blocks, text)
+		}
+	}
+	return strings.Join(blocks, "\n\n")
+}
+
+func adfBlock(node *ADFNode, listPrefix string) string {
+	switch node.Type {
+	case "paragraph":
+		return adfInline(node.Content)
+	case "heading":
+		level := 1
+		if value, ok := node.Attrs["level"].(float64); ok {
+			level = int(value)
+		}
+		return "h" + strconv.Itoa(level) + ". " + adfInline(node.Content)
+	case "bulletList", "orderedList":
+		marker := "*"
+		if node.Type == "orderedList" {
+			marker = "#"
+		}
+		items := []string{}
+		for _, item := range node.Content {
+			parts := []string{}
+			for i, child := range item.Content {
+				if i == 0 && child.Type == "paragraph" {
+					parts = append(parts, listPrefix+marker+" "+adfInline(child.Content))
+					continue
+				}
+				parts = append(parts, adfBlock(child, listPrefix+marker))
+			}
+			items = append(items, strings.Join(parts, "\n"))
+		}
+		return strings.Join(items, "\n")
+	case "codeBlock":
+		start, end := "{noformat}", "{noformat}"
+		if language, ok := node.Attrs["language"].(string); ok && language != "" {
+			start, end = "{code:"+language+"}", "{code}"
+		}
+		return start + "\n" + adfInline(node.Content) + "\n" + end
+	case "blockquote":
+		return "{quote}\n" + ADFToJira(node) + "\n{quote}"
+	case "panel", "expand":
+		start := "{panel}"
+		if title, ok := node.Attrs["title"].(string); ok && title != "" {
+			start = "{panel:title=" + title + "}"
+		}
+		return start + "\n" + ADFToJira(node) + "\n{panel}"
+	case "rule":
+		return "----"
+	case "table":
+		rows := []string{}
+		for _, row := range node.Content {
+			line := ""
+			separator := "|"
+			for _, cell := range row.Content {
+				separator = "|"
+				if cell.Type == "tableHeader" {
+					separator = "||"
+				}
+				line += separator + strings.Replace(ADFToJira(cell), "\n", " ", -1)
+			}
+			rows = append(rows, line+separator)
+		}
+		return strings.Join(rows, "\n")
+	}
+	if len(node.Content) > 0 {
+		return ADFToJira(node)
+	}
+	return adfInline([]*ADFNode{node})
+}
+
+func adfInline(nodes []*ADFNode) string {
+	result := ""
+	for i := 0; i < len(nodes); i++ {
+		node := nodes[i]
+		switch node.Type {
+		case "text":
+			// Text with the same formatting is wrapped in the effect
+			// markers once, so links inside of it stay intact.
+			text := adfLink(node)
+			for i+1 < len(nodes) && nodes[i+1].Type == "text" &&
+				formatting(nodes[i+1].Marks) == formatting(node.Marks) {
+				i++
+				text += adfLink(nodes[i])
+			}
+			result += adfFormat(text, node.Marks)
+		case "hardBreak":
+			result += "\n"
+		case "mention":
+			if id, ok := node.Attrs["id"].(string); ok {
+				result += "[~accountid:" + id + "]"
+			}
+		case "emoji":
+			if text, ok := node.Attrs["text"].(string); ok {
+				result += text
+			} else if name, ok := node.Attrs["shortName"].(string); ok {
+				result += name
+			}
+		case "inlineCard":
+			if url, ok := node.Attrs["url"].(string); ok {
+				result += "[" + url + "]"
+			}
+		}
+	}
+	return result
+}
+
+var effects = map[string][2]string{
+	"code":   {"{{", "}}"},
+	"strong": {"*", "*"},
+	"em":     {"_", "_"},
+	"strike": {"-", "-"},
+}
+
+// formatting describes the text effects of the marks, leaving out
+// links.
+func formatting(marks []*ADFMark) string {
+	result := ""
+	for _, mark := range marks {
+		if _, ok := effects[mark.Type]; ok {
+			result += mark.Type + " "
+		}
+	}
+	return result
+}
+
+func adfFormat(text string, marks []*ADFMark) string {
+	for _, mark := range marks {
+		if effect, ok := effects[mark.Type]; ok {
+			text = effect[0] + text + effect[1]
+		}
+	}
+	return text
+}
+
+func adfLink(node *ADFNode) string {
+	for _, mark := range node.Marks {
+		if href, ok := mark.Attrs["href"].(string); ok && mark.Type == "link" {
+			if href == node.Text {
+				return "[" + href + "]"
+			}
+			return "[" + node.Text + "|" + href + "]"
+		}
+	}
+	return node.Text
+}
@@ -0,0 +1,245 @@
+package markup
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// bugzillaSections are the headings of the default bugzilla bug
+// template.
+var bugzillaSections = []string{
+	"Description of problem",
+	"Version-Release number of selected component (if applicable)",
+	"Version-Release number of selected component",
+	"How reproducible",
+	"Steps to Reproduce",
+	"Actual results",
+	"Expected results",
+	"Additional info",
+}
+
+var (
+	urlPattern      = regexp.MustCompile(`https?://[^\s<>"]+[^\s<>".,;:!?)\]]`)
+	bugRefPattern   = regexp.MustCompile(`(?i)\b(bug|bz)\s*#?\s*(\d{4,})\b`)
+	numberedPattern = regexp.MustCompile(`^\s*\d+[.)]\s+(.*)$`)
+	bulletPattern   = regexp.MustCompile(`^\s*[-*]\s+(.*)$`)
+	promptPattern   = regexp.MustCompile(`^\s*(\$|#|\[\w+@[^\]]+\][$#])\s`)
+	escapePattern   = regexp.MustCompile(`([{}\[\]|!*_^~+])`)
+	leadingPattern  = regexp.MustCompile(`^(\s*)([-#])`)
+	noformatPattern = regexp.MustCompile(`(?i)\{noformat\}`)
+
+	// urlEscaper percent-encodes the characters that would end a jira
+	// link early.
+	urlEscaper = strings.NewReplacer("|", "%7C", "[", "%5B", "]", "%5D")
+
+	logLinePatterns = []*regexp.Regexp{
+		regexp.MustCompile(`^(\s{2,}|\t)\S`),                       // indented output
+		regexp.MustCompile(`\d{4}-\d{2}-\d{2}[ T]\d{2}:\d{2}`),     // ISO timestamps
+		regexp.MustCompile(`\b\d{2}:\d{2}:\d{2}\b`),                // times
+		regexp.MustCompile(`\S {3,}\S`),                            // columns
+		regexp.MustCompile(`\b(level|time|msg)=`),                  // structured logs
+		regexp.MustCompile(`^\s*[IWEF]\d{4} `),                     // klog
+		regexp.MustCompile(`\b(DEBUG|INFO|WARNING|WARN|ERROR)\b`),  // log levels
+		regexp.MustCompile(`^\s*(Traceback|panic:|goroutine \d+)`), // stack traces
+		regexp.MustCompile(`^\s*(at |File ")`),                     // stack frames
+		regexp.MustCompile(`^\s*[{}\[\]]\s*,?$`),                   // JSON
+		regexp.MustCompile(`^\s*"[^"]+":\s`),                       // JSON
+	}
+)
+
+// Attachment is a block of text too long to put in a description.
+type Attachment struct {
+	Name    string
+	Content string
+}
+
+// BugzillaOptions control the conversion of a bugzilla description.
+type BugzillaOptions struct {
+	// BugzillaURL is used to build links for bug references.
+	BugzillaURL string
+	// AttachmentPrefix is used to build the names of attachments.
+	AttachmentPrefix string
+	// MaxBlockLines is the longest log block kept in the text. Longer
+	// blocks are shortened, and the full text is returned as an
+	// attachment.
+	MaxBlockLines int
+}
+
+// DefaultMaxBlockLines is the longest log block kept in full when the
+// options do not say otherwise.
+const DefaultMaxBlockLines = 40
+
+// BugzillaToJira converts the plain text of a bugzilla description
+// into jira wiki markup. The sections of the bugzilla bug template
+// become headings, URLs and bug references become links, and log
+// output is preserved in noformat blocks.
+func BugzillaToJira(text string, opts BugzillaOptions) (string, []Attachment) {
+	if opts.MaxBlockLines <= 0 {
+		opts.MaxBlockLines = DefaultMaxBlockLines
+	}
+
+	text = strings.Replace(text, "\r\n", "\n", -1)
+
+	output := []string{}
+	attachments := []Attachment{}
+	paragraph := []string{}
+
+	flush := func() {
+		if len(paragraph) == 0 {
+			return
+		}
+		if isLogBlock(paragraph) {
+			block, attachment := formatLogBlock(paragraph, opts, len(attachments)+1)
+			output = append(output, block)
+			if attachment != nil {
+				attachments = append(attachments, *attachment)
+			}
+		} else {
+			lines := make([]string, len(paragraph))
+			for i, line := range paragraph {
+				lines[i] = formatTextLine(line, opts)
+			}
+			output = append(output, strings.Join(lines, "\n"))
+		}
+		paragraph = nil
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		if heading, rest, ok := sectionHeading(line); ok {
+			flush()
+			output = append(output, "h3. "+heading)
+			if strings.TrimSpace(rest) != "" {
+				paragraph = append(paragraph, strings.TrimSpace(rest))
+			}
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+		paragraph = append(paragraph, strings.TrimRight(line, " \t"))
+	}
+	flush()
+
+	return strings.Join(output, "\n\n"), attachments
+}
+
+// sectionHeading recognizes the headings from the bug template,
+// returning the heading and any text that follows it on the line.
+func sectionHeading(line string) (string, string, bool) {
+	trimmed := strings.TrimSpace(line)
+	for _, section := range bugzillaSections {
+		if len(trimmed) <= len(section) || !strings.EqualFold(trimmed[:len(section)], section) {
+			continue
+		}
+		rest := strings.TrimSpace(trimmed[len(section):])
+		if !strings.HasPrefix(rest, ":") {
+			continue
+		}
+		return section, rest[1:], true
+	}
+	return "", "", false
+}
+
+// isLogBlock decides whether a paragraph looks like the output of a
+// program, rather than prose.
+func isLogBlock(lines []string) bool {
+	// Commands are always shown with their output.
+	for _, line := range lines {
+		if promptPattern.MatchString(line) {
+			return true
+		}
+	}
+
+	logLines := 0
+	for _, line := range lines {
+		for _, pattern := range logLinePatterns {
+			if pattern.MatchString(line) {
+				logLines++
+				break
+			}
+		}
+	}
+	if len(lines) == 1 {
+		// A single indented or timestamped line is common in prose,
+		// so require more than one signal.
+		return logLines == 1 && strings.Contains(lines[0], "level=")
+	}
+	return logLines*2 >= len(lines)
+}
+
+func formatLogBlock(lines []string, opts BugzillaOptions, n int) (string, *Attachment) {
+	if len(lines) <= opts.MaxBlockLines {
+		return noformat(strings.Join(lines, "\n")), nil
+	}
+
+	attachment := &Attachment{
+		Name:    fmt.Sprintf("%s-log-%d.txt", opts.AttachmentPrefix, n),
+		Content: strings.Join(lines, "\n") + "\n",
+	}
+
+	// Show the start and end of the block, which is usually where
+	// the interesting bits are, and leave the rest in the attachment.
+	keep := opts.MaxBlockLines / 4
+	head := lines[:keep]
+	tail := lines[len(lines)-keep:]
+	block := noformat(fmt.Sprintf("%s\n\n... %d lines omitted ...\n\n%s",
+		strings.Join(head, "\n"),
+		len(lines)-len(head)-len(tail),
+		strings.Join(tail, "\n"),
+	))
+	block += fmt.Sprintf("\n_Full output in [^%s]_", attachment.Name)
+	return block, attachment
+}
+
+// noformat wraps text in a noformat block. jira ends the block at the
+// first {noformat} whatever comes before it, so any in the text are
+// shown escaped between two blocks instead.
+func noformat(text string) string {
+	text = noformatPattern.ReplaceAllStringFunc(text, func(token string) string {
+		return "{noformat}" + escapePattern.ReplaceAllString(token, "\\$1") + "{noformat}"
+	})
+	return "{noformat}\n" + text + "\n{noformat}"
+}
+
+// formatTextLine escapes characters jira would treat as markup and
+// turns URLs and bug references into links.
+func formatTextLine(line string, opts BugzillaOptions) string {
+	prefix := ""
+	if match := numberedPattern.FindStringSubmatch(line); match != nil {
+		prefix = "# "
+		line = match[1]
+	} else if match := bulletPattern.FindStringSubmatch(line); match != nil {
+		prefix = "* "
+		line = match[1]
+	} else if strings.HasPrefix(line, ">") {
+		prefix = "bq. "
+		line = strings.TrimSpace(strings.TrimLeft(line, ">"))
+	}
+
+	result := ""
+	last := 0
+	for _, loc := range urlPattern.FindAllStringIndex(line, -1) {
+		result += formatPlainText(line[last:loc[0]], opts)
+		result += "[" + urlEscaper.Replace(line[loc[0]:loc[1]]) + "]"
+		last = loc[1]
+	}
+	result += formatPlainText(line[last:], opts)
+
+	if prefix == "" {
+		result = leadingPattern.ReplaceAllString(result, "$1\\$2")
+	}
+	return prefix + result
+}
+
+func formatPlainText(text string, opts BugzillaOptions) string {
+	text = escapePattern.ReplaceAllString(text, "\\$1")
+	if opts.BugzillaURL == "" {
+		return text
+	}
+	return bugRefPattern.ReplaceAllStringFunc(text, func(ref string) string {
+		id := bugRefPattern.FindStringSubmatch(ref)[2]
+		return fmt.Sprintf("[%s|%s/show_bug.cgi?id=%s]", ref, opts.BugzillaURL, id)
+	})
+}
@@ -0,0 +1,97 @@
+package markup
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestBugzillaToJira(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{
+			name: "plain text is escaped",
+			text: "The installer fails with a_b and {x} [y]|z.",
+			want: "The installer fails with a\\_b and \\{x\\} \\[y\\]\\|z.",
+		},
+		{
+			name: "template sections",
+			text: "Description of problem:\nIt breaks.\n\nSteps to Reproduce:\n1. install\n2. wait",
+			want: "h3. Description of problem\n\nIt breaks.\n\nh3. Steps to Reproduce\n\n# install\n# wait",
+		},
+		{
+			name: "urls and bug references",
+			text: "See https://example.com/docs/page, and bug 1823359.",
+			want: "See [https://example.com/docs/page], and [bug 1823359|https://bugzilla.example.com/show_bug.cgi?id=1823359].",
+		},
+		{
+			name: "urls with link markup",
+			text: "Search https://example.com/search?q=a|b&f[0]=c for it.",
+			want: "Search [https://example.com/search?q=a%7Cb&f%5B0%5D=c] for it.",
+		},
+		{
+			name: "quotes",
+			text: "> quoted text\n> more",
+			want: "bq. quoted text\nbq. more",
+		},
+		{
+			name: "bullets and leading dashes",
+			text: "- x-y\n-leading dash",
+			want: "* x-y\n\\-leading dash",
+		},
+		{
+			name: "command output",
+			text: "$ oc get pods\nNAME   READY\npod-1  1/1",
+			want: "{noformat}\n$ oc get pods\nNAME   READY\npod-1  1/1\n{noformat}",
+		},
+		{
+			name: "noformat in a log",
+			text: "time=\"x\" level=error msg=\"saw {noformat} here\"\n2021-03-01 12:00:00 ERROR {NOFORMAT} again",
+			want: "{noformat}\ntime=\"x\" level=error msg=\"saw {noformat}\\{noformat\\}{noformat} here\"\n" +
+				"2021-03-01 12:00:00 ERROR {noformat}\\{NOFORMAT\\}{noformat} again\n{noformat}",
+		},
+		{
+			name: "noformat in text",
+			text: "Wrap it in {noformat} please.",
+			want: "Wrap it in \\{noformat\\} please.",
+		},
+	}
+
+	opts := BugzillaOptions{BugzillaURL: "https://bugzilla.example.com"}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, attachments := BugzillaToJira(test.text, opts)
+			if got != test.want {
+				t.Errorf("BugzillaToJira(%q)\ngot  %q\nwant %q", test.text, got, test.want)
+			}
+			if len(attachments) != 0 {
+				t.Errorf("BugzillaToJira(%q) returned %d attachments", test.text, len(attachments))
+			}
+		})
+	}
+}
+
+func TestBugzillaToJiraLongLog(t *testing.T) {
+	lines := []string{}
+	for i := 0; i < 10; i++ {
+		lines = append(lines, fmt.Sprintf("2021-03-01 12:00:%02d INFO line %d", i, i))
+	}
+	text := strings.Join(lines, "\n")
+
+	got, attachments := BugzillaToJira(text, BugzillaOptions{AttachmentPrefix: "bz123", MaxBlockLines: 8})
+
+	want := "{noformat}\n" + strings.Join(lines[:2], "\n") + "\n\n... 6 lines omitted ...\n\n" +
+		strings.Join(lines[8:], "\n") + "\n{noformat}\n_Full output in [^bz123-log-1.txt]_"
+	if got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+	if len(attachments) != 1 {
+		t.Fatalf("got %d attachments, want 1", len(attachments))
+	}
+	if attachments[0].Name != "bz123-log-1.txt" || attachments[0].Content != text+"\n" {
+		t.Errorf("got attachment %q with %q", attachments[0].Name, attachments[0].Content)
+	}
+}
@@ -0,0 +1,317 @@
+// Package markup converts the text of upstream tickets into jira wiki
+// markup.
+package markup
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	fencePattern      = regexp.MustCompile("^\\s*(```+|~~~+)\\s*([\\w+#.-]*)")
+	headingPattern    = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	listPattern       = regexp.MustCompile(`^(\s*)([-*+]|\d+[.)])\s+(.*)$`)
+	taskPattern       = regexp.MustCompile(`^\[([ xX])\]\s+(.*)$`)
+	rulePattern       = regexp.MustCompile(`^\s*([-*_])(\s*([-*_]))(\s*([-*_]))+\s*$`)
+	quotePattern      = regexp.MustCompile(`^\s*>\s?(.*)$`)
+	tableSepPattern   = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+	detailsPattern    = regexp.MustCompile(`(?i)^\s*<details[^>]*>\s*(.*)$`)
+	summaryPattern    = regexp.MustCompile(`(?i)^\s*<summary[^>]*>(.*?)</summary>\s*(.*)$`)
+	endDetailsPattern = regexp.MustCompile(`(?i)^\s*</details>\s*$`)
+	commentPattern    = regexp.MustCompile(`(?s)<!--.*?-->`)
+	tagPattern        = regexp.MustCompile(`<[^>]+>`)
+
+	inlineCodePattern = regexp.MustCompile("`([^`]+)`")
+	imagePattern      = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)(?:\s+"[^"]*")?\)`)
+	linkPattern       = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)(?:\s+"[^"]*")?\)`)
+	autoLinkPattern   = regexp.MustCompile(`<(https?://[^>\s]+)>`)
+	bareURLPattern    = regexp.MustCompile(`https?://[^\s<>()\[\]{}|"]+[^\s<>()\[\]{}|".,;:!?'*_]`)
+	boldPattern       = regexp.MustCompile(`(\*\*|__)(\S(?:.*?\S)?)(\*\*|__)`)
+	italicStarPattern = regexp.MustCompile(`(^|[^\w*])\*(\S(?:.*?\S)?)\*([^\w*]|$)`)
+	italicUndPattern  = regexp.MustCompile(`(^|[^\w_])_(\S(?:.*?\S)?)_([^\w_]|$)`)
+	strikePattern     = regexp.MustCompile(`~~(\S(?:.*?\S)?)~~`)
+	mentionPattern    = regexp.MustCompile(`(^|[^\w@/.\[])@([A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?)\b`)
+	protectedPattern  = regexp.MustCompile("\x00P(\\d+)\x00")
+
+	// wikiEscaper escapes the characters that jira would take as the
+	// start or end of markup.
+	wikiEscaper = strings.NewReplacer(
+		"{", "\\{", "}", "\\}", "[", "\\[", "]", "\\]", "*", "\\*", "_", "\\_",
+		"-", "\\-", "+", "\\+", "^", "\\^", "~", "\\~", "|", "\\|", "!", "\\!",
+	)
+)
+
+// Markers used to protect converted text from later substitutions.
+const (
+	boldMarker   = "\x00B\x00"
+	italicMarker = "\x00I\x00"
+	strikeMarker = "\x00S\x00"
+)
+
+// MarkdownOptions control the conversion of markdown.
+type MarkdownOptions struct {
+	// Mention returns the jira markup for an @login mention of a
+	// github user. Mentions are left alone when it is nil.
+	Mention func(login string) string
+}
+
+// ConvertMarkdown converts github flavored markdown into jira wiki
+// markup. Fenced code blocks, headings, lists, task lists, tables,
+// quotes, links, images, and HTML details blocks are translated. Other
+// text is escaped, so that it is shown as written rather than taken as
+// jira markup.
+func ConvertMarkdown(markdown string, opts MarkdownOptions) string {
+	markdown = strings.Replace(markdown, "\r\n", "\n", -1)
+	markdown = commentPattern.ReplaceAllString(markdown, "")
+
+	c := converter{opts: opts}
+	lines := strings.Split(markdown, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		if c.fence != "" {
+			if strings.HasPrefix(strings.TrimSpace(line), c.fence) {
+				c.emit(c.fenceEnd)
+				c.fence = ""
+				continue
+			}
+			c.emit(line)
+			continue
+		}
+
+		if match := fencePattern.FindStringSubmatch(line); match != nil {
+			c.endBlocks()
+			c.fence = match[1]
+			if match[2] != "" {
+				c.emit("{code:" + match[2] + "}")
+				c.fenceEnd = "{code}"
+			} else {
+				c.emit("{noformat}")
+				c.fenceEnd = "{noformat}"
+			}
+			continue
+		}
+
+		if match := detailsPattern.FindStringSubmatch(line); match != nil {
+			c.endBlocks()
+			rest := match[1]
+			title := ""
+			if summary := summaryPattern.FindStringSubmatch(rest); summary != nil {
+				title = summary[1]
+				rest = summary[2]
+			} else if i+1 < len(lines) {
+				if summary := summaryPattern.FindStringSubmatch(lines[i+1]); summary != nil {
+					title = summary[1]
+					rest = summary[2]
+					i++
+				}
+			}
+			if title != "" {
+				c.emit("{panel:title=" + strings.TrimSpace(stripTags(title)) + "}")
+			} else {
+				c.emit("{panel}")
+			}
+			if strings.TrimSpace(rest) != "" {
+				c.emit(c.convertInline(rest))
+			}
+			continue
+		}
+
+		if endDetailsPattern.MatchString(line) {
+			c.endBlocks()
+			c.emit("{panel}")
+			continue
+		}
+
+		if isTableRow(line) && i+1 < len(lines) && tableSepPattern.MatchString(lines[i+1]) &&
+			strings.Contains(lines[i+1], "-") {
+			c.endBlocks()
+			c.emit("||" + strings.Join(c.tableCells(line), "||") + "||")
+			i++
+			for i+1 < len(lines) && isTableRow(lines[i+1]) {
+				i++
+				c.emit("|" + strings.Join(c.tableCells(lines[i]), "|") + "|")
+			}
+			continue
+		}
+
+		if match := quotePattern.FindStringSubmatch(line); match != nil {
+			if !c.inQuote {
+				c.endBlocks()
+				c.emit("{quote}")
+				c.inQuote = true
+			}
+			c.emit(c.convertInline(match[1]))
+			continue
+		}
+		if c.inQuote {
+			c.emit("{quote}")
+			c.inQuote = false
+		}
+
+		if rulePattern.MatchString(line) {
+			c.endBlocks()
+			c.emit("----")
+			continue
+		}
+
+		if match := headingPattern.FindStringSubmatch(line); match != nil {
+			c.endBlocks()
+			c.emit("h" + strconv.Itoa(len(match[1])) + ". " + c.convertInline(match[2]))
+			continue
+		}
+
+		if match := listPattern.FindStringSubmatch(line); match != nil {
+			c.emit(c.listPrefix(match[1], match[2]) + " " + c.convertListItem(match[3]))
+			continue
+		}
+
+		if strings.TrimSpace(line) == "" {
+			c.lists = nil
+		}
+		c.emit(c.convertInline(line))
+	}
+	c.endBlocks()
+	if c.fence != "" {
+		c.emit(c.fenceEnd)
+	}
+
+	return strings.TrimRight(strings.Join(c.output, "\n"), "\n")
+}
+
+type listLevel struct {
+	indent int
+	marker string
+}
+
+type converter struct {
+	opts     MarkdownOptions
+	output   []string
+	fence    string
+	fenceEnd string
+	inQuote  bool
+	lists    []listLevel
+}
+
+func (c *converter) emit(line string) {
+	c.output = append(c.output, line)
+}
+
+// endBlocks closes any open quote and resets the list nesting.
+func (c *converter) endBlocks() {
+	if c.inQuote {
+		c.emit("{quote}")
+		c.inQuote = false
+	}
+	c.lists = nil
+}
+
+// listPrefix returns the jira list markers for an item with the given
+// indentation, tracking the nesting of the lists around it.
+func (c *converter) listPrefix(indentText, bullet string) string {
+	indent := len(strings.Replace(indentText, "\t", "    ", -1))
+	marker := "*"
+	if bullet[0] >= '0' && bullet[0] <= '9' {
+		marker = "#"
+	}
+
+	for len(c.lists) > 0 && c.lists[len(c.lists)-1].indent > indent {
+		c.lists = c.lists[:len(c.lists)-1]
+	}
+	if len(c.lists) > 0 && c.lists[len(c.lists)-1].indent == indent {
+		c.lists[len(c.lists)-1].marker = marker
+	} else {
+		c.lists = append(c.lists, listLevel{indent: indent, marker: marker})
+	}
+
+	prefix := ""
+	for _, level := range c.lists {
+		prefix += level.marker
+	}
+	return prefix
+}
+
+// convertListItem handles task list check boxes, which jira shows as
+// icons.
+func (c *converter) convertListItem(text string) string {
+	if match := taskPattern.FindStringSubmatch(text); match != nil {
+		icon := "(off)"
+		if match[1] != " " {
+			icon = "(/)"
+		}
+		return icon + " " + c.convertInline(match[2])
+	}
+	return c.convertInline(text)
+}
+
+func isTableRow(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, "|") && strings.Count(trimmed, "|") >= 2
+}
+
+func (c *converter) tableCells(line string) []string {
+	trimmed := strings.TrimSpace(line)
+	trimmed = strings.TrimPrefix(trimmed, "|")
+	trimmed = strings.TrimSuffix(trimmed, "|")
+	cells := strings.Split(trimmed, "|")
+	for i, cell := range cells {
+		cell = c.convertInline(strings.TrimSpace(cell))
+		if cell == "" {
+			// jira collapses empty cells, so give it something to
+			// show.
+			cell = " "
+		}
+		cells[i] = cell
+	}
+	return cells
+}
+
+func stripTags(text string) string {
+	return tagPattern.ReplaceAllString(text, "")
+}
+
+// convertInline translates the markup within a single line.
+func (c *converter) convertInline(text string) string {
+	// Converted pieces are swapped out for placeholders, so that
+	// nothing inside of them is escaped or treated as markup again.
+	protected := []string{}
+	protect := func(converted string) string {
+		protected = append(protected, converted)
+		return "\x00P" + strconv.Itoa(len(protected)-1) + "\x00"
+	}
+
+	text = inlineCodePattern.ReplaceAllStringFunc(text, func(m string) string {
+		return protect("{{" + m[1:len(m)-1] + "}}")
+	})
+	text = imagePattern.ReplaceAllStringFunc(text, func(m string) string {
+		return protect("!" + imagePattern.FindStringSubmatch(m)[2] + "!")
+	})
+	text = linkPattern.ReplaceAllStringFunc(text, func(m string) string {
+		match := linkPattern.FindStringSubmatch(m)
+		return protect("[" + wikiEscaper.Replace(match[1]) + "|" + match[2] + "]")
+	})
+	text = autoLinkPattern.ReplaceAllStringFunc(text, func(m string) string {
+		return protect("[" + autoLinkPattern.FindStringSubmatch(m)[1] + "]")
+	})
+	text = bareURLPattern.ReplaceAllStringFunc(text, protect)
+	if c.opts.Mention != nil {
+		text = mentionPattern.ReplaceAllStringFunc(text, func(m string) string {
+			match := mentionPattern.FindStringSubmatch(m)
+			return match[1] + protect(c.opts.Mention(match[2]))
+		})
+	}
+
+	text = boldPattern.ReplaceAllString(text, boldMarker+"$2"+boldMarker)
+	text = italicStarPattern.ReplaceAllString(text, "${1}"+italicMarker+"${2}"+italicMarker+"${3}")
+	text = italicUndPattern.ReplaceAllString(text, "${1}"+italicMarker+"${2}"+italicMarker+"${3}")
+	text = strikePattern.ReplaceAllString(text, strikeMarker+"$1"+strikeMarker)
+
+	text = wikiEscaper.Replace(text)
+
+	text = strings.NewReplacer(boldMarker, "*", italicMarker, "_", strikeMarker, "-").Replace(text)
+	return protectedPattern.ReplaceAllStringFunc(text, func(m string) string {
+		n, _ := strconv.Atoi(protectedPattern.FindStringSubmatch(m)[1])
+		return protected[n]
+	})
+}
@@ -0,0 +1,113 @@
+package markup
+
+import "testing"
+
+func TestConvertMarkdown(t *testing.T) {
+	mention := func(login string) string { return "[~" + login + "]" }
+
+	tests := []struct {
+		name     string
+		markdown string
+		want     string
+	}{
+		{
+			name:     "fence with language",
+			markdown: "```go\nfunc main() {}\n```",
+			want:     "{code:go}\nfunc main() {}\n{code}",
+		},
+		{
+			name:     "fence without language",
+			markdown: "```\n$ make *build*\n```",
+			want:     "{noformat}\n$ make *build*\n{noformat}",
+		},
+		{
+			name:     "unclosed fence",
+			markdown: "~~~\nlog line",
+			want:     "{noformat}\nlog line\n{noformat}",
+		},
+		{
+			name:     "inline code",
+			markdown: "run `make [all]` now",
+			want:     "run {{make [all]}} now",
+		},
+		{
+			name:     "nested list",
+			markdown: "- one\n  - two\n    - three\n- four",
+			want:     "* one\n** two\n*** three\n* four",
+		},
+		{
+			name:     "ordered list",
+			markdown: "1. first\n2. second\n   1. nested",
+			want:     "# first\n# second\n## nested",
+		},
+		{
+			name:     "task list",
+			markdown: "- [ ] todo\n- [x] done",
+			want:     "* (off) todo\n* (/) done",
+		},
+		{
+			name:     "table with an empty cell",
+			markdown: "| a | b |\n|---|---|\n| 1 |   |\n| x-y | z |",
+			want:     "||a||b||\n|1| |\n|x\\-y|z|",
+		},
+		{
+			name:     "links",
+			markdown: "see [the docs](https://example.com/a_b) and <https://example.com/x>",
+			want:     "see [the docs|https://example.com/a_b] and [https://example.com/x]",
+		},
+		{
+			name:     "bare url",
+			markdown: "see https://github.com/foo/bar_baz/issues/1.",
+			want:     "see https://github.com/foo/bar_baz/issues/1.",
+		},
+		{
+			name:     "image",
+			markdown: "![logo](https://example.com/logo.png)",
+			want:     "!https://example.com/logo.png!",
+		},
+		{
+			name:     "details with summary",
+			markdown: "<details>\n<summary>Logs</summary>\n\nsome text\n</details>",
+			want:     "{panel:title=Logs}\n\nsome text\n{panel}",
+		},
+		{
+			name:     "details without summary",
+			markdown: "<details>\nsome text\n</details>",
+			want:     "{panel}\nsome text\n{panel}",
+		},
+		{
+			name:     "mentions",
+			markdown: "thanks @some-user and @other, mail a@b.com",
+			want:     "thanks [~some-user] and [~other], mail a@b.com",
+		},
+		{
+			name:     "emphasis",
+			markdown: "**bold** and *italic* and _also_ and ~~gone~~",
+			want:     "*bold* and _italic_ and _also_ and -gone-",
+		},
+		{
+			name:     "plain text is escaped",
+			markdown: "use {braces} and [brackets] and a|b and snake_case and x-y and 2*3*4",
+			want:     "use \\{braces\\} and \\[brackets\\] and a\\|b and snake\\_case and x\\-y and 2\\*3\\*4",
+		},
+		{
+			name:     "heading",
+			markdown: "## Title with [x]",
+			want:     "h2. Title with \\[x\\]",
+		},
+		{
+			name:     "quote",
+			markdown: "> quoted\n> text\n\nafter",
+			want:     "{quote}\nquoted\ntext\n{quote}\n\nafter",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := ConvertMarkdown(test.markdown, MarkdownOptions{Mention: mention})
+			if got != test.want {
+				t.Errorf("ConvertMarkdown(%q)\ngot  %q\nwant %q", test.markdown, got, test.want)
+			}
+		})
+	}
+}
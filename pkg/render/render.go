@@ -0,0 +1,257 @@
+// Package render builds the text of jira tickets and comments from
+// templates, expanded with the upstream item the ticket is created
+// from.
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v2"
+)
+
+// maxSummary is the longest summary created. Jira allows 255
+// characters, leave some room for people editing the ticket.
+const maxSummary = 250
+
+// Item describes the upstream ticket a jira ticket is created from.
+type Item struct {
+	// Source is "github" or "bugzilla".
+	Source string
+	Slug   string
+	URL    string
+	Title  string
+	// Number is the github issue number or the bug ID.
+	Number int
+	// Body is the description of the upstream ticket in jira wiki
+	// markup. It is only set when creating tickets.
+	Body    string
+	Author  string
+	Created time.Time
+	Labels  []string
+
+	// Org, Repo, Milestone, and Assignees are set for github issues.
+	Org       string
+	Repo      string
+	Milestone string
+	Assignees []string
+
+	// Product, Status, Severity, and Priority are set for bugzilla
+	// bugs.
+	Product  string
+	Status   string
+	Severity string
+	Priority string
+}
+
+// Sample has every field filled in, for trying out templates before
+// there is a real item.
+var Sample = &Item{
+	Source:    "github",
+	Slug:      "github:org:repo:1",
+	URL:       "https://github.com/org/repo/issues/1",
+	Title:     "title",
+	Number:    1,
+	Body:      "body",
+	Author:    "author",
+	Created:   time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+	Labels:    []string{"label"},
+	Org:       "org",
+	Repo:      "repo",
+	Milestone: "milestone",
+	Assignees: []string{"assignee"},
+	Product:   "product",
+	Status:    "status",
+	Severity:  "severity",
+	Priority:  "priority",
+}
+
+// Funcs are the functions available to templates, in addition to the
+// text/template builtins.
+var Funcs = template.FuncMap{
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// Parse parses a template using Funcs and checks it against Sample.
+func Parse(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(Funcs).Option("missingkey=error").Parse(text)
+	if err == nil {
+		// Catch references to things an Item does not have.
+		err = tmpl.Execute(ioutil.Discard, Sample)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// Execute expands the template for the item.
+func Execute(tmpl *template.Template, item *Item) (string, error) {
+	text := &bytes.Buffer{}
+	if err := tmpl.Execute(text, item); err != nil {
+		return "", fmt.Errorf("could not expand %s: %s", tmpl.Name(), err)
+	}
+	return text.String(), nil
+}
+
+// Templates hold the templates for the text of tickets and comments.
+// A nil Templates uses the defaults.
+type Templates struct {
+	summary       *template.Template
+	description   *template.Template
+	closedComment *template.Template
+}
+
+// The default templates create the same text as before templates
+// could be configured.
+const (
+	DefaultSummary       = "{{.Title}} [{{.Slug}}]"
+	DefaultDescription   = "_created automatically from [{{.Slug}}|{{.URL}}]_\n\n{{.Body}}"
+	DefaultClosedComment = "The upstream ticket has been closed."
+)
+
+var defaults = &Templates{
+	summary:       template.Must(Parse("summary", DefaultSummary)),
+	description:   template.Must(Parse("description", DefaultDescription)),
+	closedComment: template.Must(Parse("closed-comment", DefaultClosedComment)),
+}
+
+type templatesFile struct {
+	Summary       string `yaml:"summary"`
+	Description   string `yaml:"description"`
+	ClosedComment string `yaml:"closed-comment"`
+}
+
+// Load reads a YAML file of templates of the form
+//
+//	summary: '{{.Title}} [{{.Slug}}]'
+//	description: |
+//	  _created automatically from [{{.Slug}}|{{.URL}}]_
+//
+//	  {{.Body}}
+//	closed-comment: The upstream ticket has been closed.
+//
+// Templates left out use the defaults.
+func Load(filename string) (*Templates, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	parsed := templatesFile{}
+	if err := yaml.UnmarshalStrict(content, &parsed); err != nil {
+		return nil, fmt.Errorf("Unable to parse templates %s: %s", filename, err)
+	}
+
+	result := &Templates{}
+	for _, t := range []struct {
+		name  string
+		text  string
+		def   string
+		value **template.Template
+	}{
+		{"summary", parsed.Summary, DefaultSummary, &result.summary},
+		{"description", parsed.Description, DefaultDescription, &result.description},
+		{"closed-comment", parsed.ClosedComment, DefaultClosedComment, &result.closedComment},
+	} {
+		text := t.text
+		if text == "" {
+			text = t.def
+		}
+		*t.value, err = Parse(t.name, text)
+		if err != nil {
+			return nil, fmt.Errorf("Bad %s template in %s: %s", t.name, filename, err)
+		}
+	}
+	return result, nil
+}
+
+func (t *Templates) get() *Templates {
+	if t == nil {
+		return defaults
+	}
+	return t
+}
+
+// Summary builds the summary of a new ticket. The result always
+// contains the slug, so the ticket can be found again, and is
+// shortened by trimming the title if it is too long.
+func (t *Templates) Summary(item *Item) (string, error) {
+	tmpl := t.get().summary
+	summary, err := Execute(tmpl, item)
+	if err != nil {
+		return "", err
+	}
+	summary = oneLine(summary)
+
+	// Trimming the title keeps the rest of the template intact, if
+	// the title is what makes it too long.
+	if over := utf8.RuneCountInString(summary) - maxSummary; over > 0 {
+		shorter := *item
+		shorter.Title = Truncate(item.Title, utf8.RuneCountInString(item.Title)-over)
+		summary, err = Execute(tmpl, &shorter)
+		if err != nil {
+			return "", err
+		}
+		summary = oneLine(summary)
+	}
+
+	if strings.Contains(summary, item.Slug) && utf8.RuneCountInString(summary) <= maxSummary {
+		return summary, nil
+	}
+
+	// Otherwise drop the slug, so a partial copy of it cannot be
+	// mistaken for another one, and put it at the end where it
+	// cannot be cut off.
+	suffix := fmt.Sprintf(" [%s]", item.Slug)
+	summary = strings.TrimSpace(strings.Replace(summary, item.Slug, "", 1))
+	return Truncate(summary, maxSummary-utf8.RuneCountInString(suffix)) + suffix, nil
+}
+
+// Description builds the description of a new ticket. The result
+// always links to the upstream ticket with the slug as the text of the
+// link, which is how find-closed finds the upstream ticket.
+func (t *Templates) Description(item *Item) (string, error) {
+	description, err := Execute(t.get().description, item)
+	if err != nil {
+		return "", err
+	}
+	link := fmt.Sprintf("[%s|%s]", item.Slug, item.URL)
+	if !strings.Contains(description, link) {
+		description = fmt.Sprintf("_created automatically from %s_\n\n%s", link, description)
+	}
+	return description, nil
+}
+
+// ClosedComment builds the comment added to tickets whose upstream
+// ticket was closed.
+func (t *Templates) ClosedComment(item *Item) (string, error) {
+	return Execute(t.get().closedComment, item)
+}
+
+// oneLine joins the lines of a summary, which cannot have more than
+// one.
+func oneLine(text string) string {
+	return strings.TrimSpace(strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ").Replace(text))
+}
+
+// Truncate shortens the text to at most n characters, counting runes
+// rather than bytes, and marks it with an ellipsis if anything was
+// removed.
+func Truncate(text string, n int) string {
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	n -= 3
+	if n < 0 {
+		n = 0
+	}
+	return string(runes[:n]) + "..."
+}
@@ -0,0 +1,283 @@
+// Package retry provides the HTTP transport shared by the jira,
+// github, and bugzilla clients. It retries requests that failed in a
+// way that is likely to go away, backing off between attempts, and
+// waits out rate limits instead of failing.
+package retry
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Transport is an http.RoundTripper that retries through Base.
+//
+// Requests that may be repeated safely are retried after network
+// errors and 502, 503, and 504 responses. Any request is retried
+// after a 429, or a 403 that is a github rate limit, since those were
+// not processed. The wait before a retry is taken from Retry-After or
+// X-RateLimit-Reset if the server sends them, and is otherwise an
+// exponential backoff with jitter. When X-RateLimit-Remaining shows the
+// quota is nearly used up, the next request waits for it to be reset.
+type Transport struct {
+	Base http.RoundTripper
+
+	// Attempts is the most times a request is sent.
+	Attempts int
+	// MinDelay is the backoff before the first retry, which doubles
+	// for each one after up to MaxDelay.
+	MinDelay time.Duration
+	MaxDelay time.Duration
+	// MaxWait is the longest the transport waits for a rate limit.
+	// Requests that would have to wait longer fail.
+	MaxWait time.Duration
+	// LowQuota is the number of requests left in the rate limit
+	// below which requests wait for the reset.
+	LowQuota int
+	// Timeout limits each attempt, including reading the response
+	// body, without cutting short the waits between attempts. Zero
+	// means no limit.
+	Timeout time.Duration
+
+	mutex       sync.Mutex
+	random      *rand.Rand
+	pausedUntil time.Time
+}
+
+// New returns a transport with the default settings that sends
+// requests through base, or the default transport if base is nil.
+func New(base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{
+		Base:     base,
+		Attempts: 5,
+		MinDelay: time.Second,
+		MaxDelay: time.Minute,
+		MaxWait:  time.Hour,
+		LowQuota: 10,
+		random:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// RoundTrip sends the request, retrying it as needed.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		if err := t.waitForQuota(req); err != nil {
+			return nil, err
+		}
+
+		try := req
+		if attempt > 1 && req.Body != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			try = req.Clone(req.Context())
+			try.Body = body
+		}
+		cancel := func() {}
+		if t.Timeout > 0 {
+			var ctx context.Context
+			ctx, cancel = context.WithTimeout(req.Context(), t.Timeout)
+			try = try.WithContext(ctx)
+		}
+
+		resp, err := t.Base.RoundTrip(try)
+		if resp != nil {
+			t.checkQuota(resp)
+		}
+
+		reason, delay := t.retryable(req, resp, err, attempt)
+		if reason == "" || attempt >= t.Attempts || delay > t.MaxWait {
+			if resp == nil {
+				cancel()
+				return resp, err
+			}
+			// The deadline has to last until the body is read.
+			resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+			return resp, err
+		}
+		if resp != nil {
+			ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+		cancel()
+
+		fmt.Fprintf(os.Stderr, "Warning: %s %s: %s, retrying in %s\n",
+			req.Method, req.URL.Host, reason, delay.Round(100*time.Millisecond))
+		if err := sleep(req, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// cancelBody releases the context of an attempt when its response
+// body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// retryable returns why the request should be sent again and how long
+// to wait first, or an empty reason if it should not be.
+func (t *Transport) retryable(req *http.Request, resp *http.Response, err error, attempt int) (string, time.Duration) {
+	// A body that cannot be read again cannot be resent.
+	if req.Body != nil && req.GetBody == nil {
+		return "", 0
+	}
+
+	if err != nil {
+		if !idempotent(req.Method) {
+			return "", 0
+		}
+		return err.Error(), t.backoff(attempt)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+	case resp.StatusCode == http.StatusForbidden && rateLimited(resp):
+	case resp.StatusCode == http.StatusBadGateway,
+		resp.StatusCode == http.StatusServiceUnavailable,
+		resp.StatusCode == http.StatusGatewayTimeout:
+		if !idempotent(req.Method) {
+			return "", 0
+		}
+	default:
+		return "", 0
+	}
+
+	delay, ok := serverDelay(resp)
+	if !ok {
+		delay = t.backoff(attempt)
+	}
+	return resp.Status, delay
+}
+
+// backoff returns the wait before the given retry, doubling with each
+// attempt, with jitter so clients that failed together do not retry
+// together.
+func (t *Transport) backoff(attempt int) time.Duration {
+	delay := t.MinDelay
+	for i := 1; i < attempt && delay < t.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > t.MaxDelay {
+		delay = t.MaxDelay
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return delay/2 + time.Duration(t.random.Int63n(int64(delay/2)+1))
+}
+
+// checkQuota remembers when to resume if the server says the rate
+// limit is nearly used up.
+func (t *Transport) checkQuota(resp *http.Response) {
+	remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	if err != nil || remaining > t.LowQuota {
+		return
+	}
+	reset, ok := resetTime(resp)
+	if !ok {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if reset.After(t.pausedUntil) {
+		t.pausedUntil = reset
+	}
+}
+
+// waitForQuota waits for the rate limit to be reset, if it is nearly
+// used up.
+func (t *Transport) waitForQuota(req *http.Request) error {
+	t.mutex.Lock()
+	delay := time.Until(t.pausedUntil)
+	t.mutex.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+	if delay > t.MaxWait {
+		return fmt.Errorf("the rate limit for %s resets in %s, which is too long to wait",
+			req.URL.Host, delay.Round(time.Second))
+	}
+	fmt.Fprintf(os.Stderr, "Warning: %s: the rate limit is nearly used up, waiting %s for it to reset\n",
+		req.URL.Host, delay.Round(time.Second))
+	return sleep(req, delay)
+}
+
+// serverDelay returns the wait the server asked for.
+func serverDelay(resp *http.Response) (time.Duration, bool) {
+	if value := resp.Header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil {
+			return time.Duration(seconds) * time.Second, true
+		}
+		if when, err := http.ParseTime(value); err == nil {
+			return positive(time.Until(when)), true
+		}
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if reset, ok := resetTime(resp); ok {
+			return positive(time.Until(reset)), true
+		}
+	}
+	return 0, false
+}
+
+// resetTime parses X-RateLimit-Reset, which github sends as a unix
+// time.
+func resetTime(resp *http.Response) (time.Time, bool) {
+	seconds, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	// Allow for the clocks being a little apart.
+	return time.Unix(seconds, 0).Add(time.Second), true
+}
+
+// rateLimited returns true if a 403 response is github refusing the
+// request because of a rate limit, rather than for lack of access.
+func rateLimited(resp *http.Response) bool {
+	return resp.Header.Get("Retry-After") != "" || resp.Header.Get("X-RateLimit-Remaining") == "0"
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func positive(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// sleep waits for the delay, or until the request is canceled.
+func sleep(req *http.Request, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
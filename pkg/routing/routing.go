@@ -0,0 +1,196 @@
+// Package routing chooses the epic or parent, and the watchers, for
+// new tickets based on the github issue they are imported from.
+package routing
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	"gopkg.in/yaml.v2"
+
+	"github.com/openshift-metal3/jira-sync/pkg/target"
+)
+
+// Rule matches github issues and names the epic or parent, and any
+// extra watchers, for their tickets. Every condition given must
+// match. A rule without conditions matches everything, and is useful
+// as a default at the end of the list.
+type Rule struct {
+	// Repo is the repository name, or org/name.
+	Repo string `yaml:"repo"`
+	// Label must be one of the labels on the issue.
+	Label string `yaml:"label"`
+	// Title is a regular expression matched against the issue title.
+	Title string `yaml:"title"`
+
+	// Epic is the key of the epic to link the ticket to.
+	Epic string `yaml:"epic"`
+	// Parent is the key of the parent ticket, for sub-tasks or for
+	// projects using the Jira Cloud issue hierarchy.
+	Parent string `yaml:"parent"`
+	// Watchers are added to the ticket, as jira usernames or account
+	// IDs with the accountid: prefix.
+	Watchers []string `yaml:"watchers"`
+
+	titlePattern *regexp.Regexp
+}
+
+// Rules is an ordered list of rules. The first rule that matches an
+// issue is used. A nil Rules is valid and matches nothing.
+type Rules struct {
+	rules []*Rule
+}
+
+type rulesFile struct {
+	Rules []*Rule `yaml:"rules"`
+}
+
+// Load reads a YAML rules file of the form
+//
+//	rules:
+//	- repo: baremetal-operator
+//	  label: kind/bug
+//	  epic: MY_THING-123
+//	- title: '(?i)\bironic\b'
+//	  parent: MY_THING-456
+//	  watchers: [janedoe]
+func Load(filename string) (*Rules, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed := rulesFile{}
+	if err := yaml.UnmarshalStrict(content, &parsed); err != nil {
+		return nil, fmt.Errorf("Unable to parse routing rules %s: %s", filename, err)
+	}
+
+	for i, rule := range parsed.Rules {
+		if rule.Epic != "" && rule.Parent != "" {
+			return nil, fmt.Errorf("Rule %d in %s must have only one of epic or parent",
+				i+1, filename)
+		}
+		if rule.Epic == "" && rule.Parent == "" && len(rule.Watchers) == 0 {
+			return nil, fmt.Errorf("Rule %d in %s must have an epic, parent, or watchers",
+				i+1, filename)
+		}
+		if rule.Title != "" {
+			rule.titlePattern, err = regexp.Compile(rule.Title)
+			if err != nil {
+				return nil, fmt.Errorf("Rule %d in %s has a bad title pattern: %s",
+					i+1, filename, err)
+			}
+		}
+	}
+
+	return &Rules{rules: parsed.Rules}, nil
+}
+
+// Check makes sure the tickets the rules name exist and that the
+// target issue type can be linked to them.
+func (r *Rules) Check(t *target.Target, client *jira.Client) error {
+	if r == nil {
+		return nil
+	}
+	checked := make(map[string]bool)
+	for _, rule := range r.rules {
+		if rule.Epic != "" {
+			if t.Parent != "" {
+				return fmt.Errorf("sub-tasks inherit the epic of their parent, so rules cannot set an epic")
+			}
+			if !t.AllowsEpic() {
+				return fmt.Errorf("issue type %s in %s cannot be linked to an epic",
+					t.IssueTypeName, t.Project)
+			}
+			if !checked[rule.Epic] {
+				if err := target.CheckEpic(client, rule.Epic); err != nil {
+					return err
+				}
+				checked[rule.Epic] = true
+			}
+		}
+		if rule.Parent != "" && !t.AllowsParent() {
+			return fmt.Errorf("issue type %s in %s cannot have a parent, so rules cannot set one",
+				t.IssueTypeName, t.Project)
+		}
+		if rule.Parent != "" && !checked[rule.Parent] {
+			if err := target.CheckParent(client, rule.Parent); err != nil {
+				return err
+			}
+			checked[rule.Parent] = true
+		}
+	}
+	return nil
+}
+
+// Match returns the first rule that matches the issue, or nil.
+func (r *Rules) Match(org, repo string, labels []string, title string) *Rule {
+	if r == nil {
+		return nil
+	}
+	for _, rule := range r.rules {
+		if rule.matches(org, repo, labels, title) {
+			return rule
+		}
+	}
+	return nil
+}
+
+func (rule *Rule) matches(org, repo string, labels []string, title string) bool {
+	if rule.Repo != "" {
+		name := repo
+		if strings.Contains(rule.Repo, "/") {
+			name = org + "/" + repo
+		}
+		if !strings.EqualFold(rule.Repo, name) {
+			return false
+		}
+	}
+	if rule.Label != "" {
+		found := false
+		for _, label := range labels {
+			if strings.EqualFold(rule.Label, label) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if rule.titlePattern != nil && !rule.titlePattern.MatchString(title) {
+		return false
+	}
+	return true
+}
+
+// Apply links a new ticket to the epic or parent of the rule,
+// replacing any set from the command line.
+func (rule *Rule) Apply(t *target.Target, fields *jira.IssueFields) {
+	switch {
+	case rule.Epic != "":
+		fields.Parent = nil
+		t.SetEpic(fields, rule.Epic)
+	case rule.Parent != "":
+		t.ClearEpic(fields)
+		fields.Parent = &jira.Parent{
+			Key: rule.Parent,
+		}
+	}
+}
+
+// Routes returns true if the rule picks the epic or parent.
+func (rule *Rule) Routes() bool {
+	return rule.Epic != "" || rule.Parent != ""
+}
+
+// String describes where the rule sends tickets.
+func (rule *Rule) String() string {
+	if rule.Epic != "" {
+		return "epic " + rule.Epic
+	}
+	return "parent " + rule.Parent
+}
@@ -0,0 +1,114 @@
+package routing
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andygrunwald/go-jira"
+
+	"github.com/openshift-metal3/jira-sync/pkg/target"
+)
+
+const createMeta = `{"projects": [{"key": "MY", "issuetypes": [
+	{"name": "Story", "fields": {
+		"summary": {"name": "Summary", "required": true},
+		"customfield_10008": {"name": "Epic Link"}
+	}},
+	{"name": "Task", "fields": {
+		"summary": {"name": "Summary", "required": true},
+		"parent": {"name": "Parent"}
+	}},
+	{"name": "Sub-task", "subtask": true, "fields": {
+		"summary": {"name": "Summary", "required": true},
+		"parent": {"name": "Parent", "required": true}
+	}}
+]}]}`
+
+func resolveTarget(t *testing.T, opts target.Options) *target.Target {
+	meta := &jira.CreateMetaInfo{}
+	if err := json.Unmarshal([]byte(createMeta), meta); err != nil {
+		t.Fatal(err)
+	}
+	opts.Project = "MY"
+	result, err := target.Resolve(meta, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+// jiraClient returns a client for a server that has a story for every
+// key.
+func jiraClient(t *testing.T) *jira.Client {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		fmt.Fprintf(w, `{"key": %q, "fields": {"issuetype": {"name": "Story"}}}`, key)
+	}))
+	t.Cleanup(server.Close)
+	client, err := jira.NewClient(nil, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestCheck(t *testing.T) {
+	story := resolveTarget(t, target.Options{IssueType: "Story"})
+	task := resolveTarget(t, target.Options{IssueType: "Task"})
+	subtask := resolveTarget(t, target.Options{IssueType: "Sub-task", Parent: "MY-1"})
+
+	tests := []struct {
+		name    string
+		rule    Rule
+		target  *target.Target
+		wantErr string
+	}{
+		{
+			name:   "parent for a sub-task",
+			rule:   Rule{Parent: "MY-2"},
+			target: subtask,
+		},
+		{
+			name:   "parent in the cloud hierarchy",
+			rule:   Rule{Parent: "MY-2"},
+			target: task,
+		},
+		{
+			name:    "parent for a story",
+			rule:    Rule{Parent: "MY-2"},
+			target:  story,
+			wantErr: "issue type Story in MY cannot have a parent, so rules cannot set one",
+		},
+		{
+			name:    "epic for a sub-task",
+			rule:    Rule{Epic: "MY-3"},
+			target:  subtask,
+			wantErr: "sub-tasks inherit the epic of their parent, so rules cannot set an epic",
+		},
+		{
+			name:   "watchers only",
+			rule:   Rule{Watchers: []string{"jdoe"}},
+			target: story,
+		},
+	}
+
+	client := jiraClient(t)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rules := &Rules{rules: []*Rule{&test.rule}}
+			err := rules.Check(test.target, client)
+			switch {
+			case test.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case test.wantErr != "" && err == nil:
+				t.Errorf("no error, want %q", test.wantErr)
+			case test.wantErr != "" && err.Error() != test.wantErr:
+				t.Errorf("got error %q, want %q", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package settings
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// readFile reads a settings file. Files ending in .yaml or .yml hold
+// Settings, anything else is treated as the shell variable
+// assignments sourced by the scripts, such as
+//
+//	jira_user=janedoe
+//	jira_password_file=/etc/jira-sync/secrets/jira-password
+//
+// Relative names of secret files are taken from the directory of the
+// settings file.
+func readFile(filename string) (map[string]string, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read settings: %v", err)
+	}
+
+	switch filepath.Ext(filename) {
+	case ".yaml", ".yml":
+		s := Settings{}
+		if err := yaml.Unmarshal(content, &s); err != nil {
+			return nil, fmt.Errorf("Unable to parse settings %s: %s", filename, RedactYAMLError(err))
+		}
+		s.ResolvePaths(filepath.Dir(filename))
+		return s.values(), nil
+	}
+
+	results := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Unable to parse settings %s: line %d is not an assignment", filename, n)
+		}
+		key, value := strings.TrimSpace(parts[0]), unquote(strings.TrimSpace(parts[1]))
+		if strings.HasSuffix(key, "_file") && value != "" && !filepath.IsAbs(value) {
+			value = filepath.Join(filepath.Dir(filename), value)
+		}
+		results[key] = value
+	}
+	return results, scanner.Err()
+}
+
+// unquote removes the quotes around a shell value. Variables are not
+// expanded.
+func unquote(value string) string {
+	if len(value) >= 2 {
+		first, last := value[0], value[len(value)-1]
+		if (first == '\'' || first == '"') && first == last {
+			return value[1 : len(value)-1]
+		}
+	}
+	return value
+}
+
+// envName returns the environment variable for the setting.
+func envName(key string) string {
+	return strings.ToUpper(key)
+}
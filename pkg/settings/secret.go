@@ -0,0 +1,52 @@
+package settings
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// readSecretFile reads a secret from a file, such as a mounted
+// kubernetes secret. Trailing newlines are dropped.
+func readSecretFile(description, filename string) (string, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", fmt.Errorf("Could not read the %s: %v", description, err)
+	}
+	value := strings.TrimRight(string(content), "\r\n")
+	if value == "" {
+		return "", fmt.Errorf("The %s file %s is empty", description, filename)
+	}
+	return value, nil
+}
+
+// runSecretCommand runs a command with the shell and returns what it
+// prints, so the secret can come from a password manager. The
+// command's own errors go to stderr, its output is never reported.
+func runSecretCommand(description, command string) (string, error) {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("The command for the %s failed: %v", description, err)
+	}
+	value := strings.TrimRight(string(output), "\r\n")
+	if value == "" {
+		return "", fmt.Errorf("The command for the %s printed nothing", description)
+	}
+	return value, nil
+}
+
+// yamlValuePattern matches the values the YAML parser quotes in its
+// errors.
+var yamlValuePattern = regexp.MustCompile("`[^`]*`")
+
+// RedactYAMLError removes the values quoted in a YAML parse error,
+// which may be a password that was put in the wrong place.
+func RedactYAMLError(err error) string {
+	return yamlValuePattern.ReplaceAllString(err.Error(), "`...`")
+}
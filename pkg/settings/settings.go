@@ -0,0 +1,468 @@
+// Package settings resolves the credentials and server URLs shared by
+// the jira-sync commands. Each value may come from a command line
+// flag, an environment variable, or a settings file, in that order of
+// preference. Passwords and tokens may also be read from a file or
+// the output of a command, so they do not have to be passed as
+// arguments where they show up in ps.
+package settings
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/andygrunwald/go-jira"
+	"golang.org/x/oauth2"
+
+	"github.com/openshift-metal3/jira-sync/pkg/bugzilla"
+	"github.com/openshift-metal3/jira-sync/pkg/jiraauth"
+	"github.com/openshift-metal3/jira-sync/pkg/jirabackend"
+	"github.com/openshift-metal3/jira-sync/pkg/retry"
+)
+
+// Jira holds the jira server settings.
+type Jira struct {
+	URL             string `yaml:"url"`
+	Auth            string `yaml:"auth"`
+	User            string `yaml:"user"`
+	Password        string `yaml:"password"`
+	PasswordFile    string `yaml:"password-file"`
+	PasswordCommand string `yaml:"password-command"`
+	Token           string `yaml:"token"`
+	TokenFile       string `yaml:"token-file"`
+	TokenCommand    string `yaml:"token-command"`
+}
+
+// Github holds the github settings.
+type Github struct {
+	Token        string `yaml:"token"`
+	TokenFile    string `yaml:"token-file"`
+	TokenCommand string `yaml:"token-command"`
+}
+
+// Bugzilla holds the bugzilla server settings.
+type Bugzilla struct {
+	URL             string `yaml:"url"`
+	Token           string `yaml:"token"`
+	TokenFile       string `yaml:"token-file"`
+	TokenCommand    string `yaml:"token-command"`
+	Auth            string `yaml:"auth"`
+	User            string `yaml:"user"`
+	Password        string `yaml:"password"`
+	PasswordFile    string `yaml:"password-file"`
+	PasswordCommand string `yaml:"password-command"`
+}
+
+// Audit holds the audit log settings.
+type Audit struct {
+	Log string `yaml:"log"`
+}
+
+// Settings are the values shared by the commands. The YAML form is
+// also used for the settings file and the server sections of the
+// jira-sync run config.
+type Settings struct {
+	Jira     Jira     `yaml:"jira"`
+	Github   Github   `yaml:"github"`
+	Bugzilla Bugzilla `yaml:"bugzilla"`
+	Audit    Audit    `yaml:"audit"`
+}
+
+// SettingsFileEnv names the environment variable giving the default
+// settings file.
+const SettingsFileEnv = "JIRA_SYNC_SETTINGS"
+
+// DefaultSettingsFile is read when no other settings file is given.
+// It uses the shell syntax of the settings sourced by the scripts.
+const DefaultSettingsFile = "~/.jira_sync_settings"
+
+// NoAuditLog is given as the audit log to turn the log off.
+const NoAuditLog = "none"
+
+// source connects one setting to the places it can come from.
+type source struct {
+	flag  string
+	env   string
+	key   string
+	value *string
+
+	// Secrets may also be given as the name of a file holding the
+	// value, or a command printing it. The description names the
+	// secret in errors, which never include the value itself.
+	secret      bool
+	description string
+	file        string
+	command     string
+}
+
+// Flags registers the settings on the flag set of a command and fills
+// them in from the other sources once the flags have been parsed.
+type Flags struct {
+	Settings
+
+	file    string
+	sources []*source
+	audit   bool
+}
+
+// NewFlags adds the -settings flag to the flag set. The Add methods
+// add the flags for each server the command talks to.
+func NewFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{}
+	fs.StringVar(&f.file, "settings", "",
+		fmt.Sprintf("the settings file, in YAML or shell syntax (default $%s or %s)",
+			SettingsFileEnv, DefaultSettingsFile))
+	return f
+}
+
+func (f *Flags) add(fs *flag.FlagSet, name, key, usage string, value *string) *source {
+	env := envName(key)
+	fs.StringVar(value, name, "", fmt.Sprintf("%s (or $%s)", usage, env))
+	src := &source{flag: name, env: env, key: key, value: value}
+	f.sources = append(f.sources, src)
+	return src
+}
+
+// addSecret adds a setting that may also be read from a file or the
+// output of a command, with the -file and -command flags for it.
+func (f *Flags) addSecret(fs *flag.FlagSet, name, key, description, usage string, value *string) {
+	src := f.add(fs, name, key, usage, value)
+	src.secret = true
+	src.description = description
+	fs.StringVar(&src.file, name+"-file", "",
+		fmt.Sprintf("a file holding the %s (or $%s)", description, envName(key+"_file")))
+	fs.StringVar(&src.command, name+"-command", "",
+		fmt.Sprintf("a command that prints the %s (or $%s)", description, envName(key+"_command")))
+}
+
+// AddJira adds the jira flags.
+func (f *Flags) AddJira(fs *flag.FlagSet) {
+	f.add(fs, "jira-user", "jira_user", "the jira username, or email address for jira cloud", &f.Jira.User)
+	f.addSecret(fs, "jira-password", "jira_password", "jira password", "the jira password", &f.Jira.Password)
+	f.addSecret(fs, "jira-token", "jira_token", "jira token",
+		"the jira personal access token, or API token for jira cloud", &f.Jira.Token)
+	f.add(fs, "jira-auth", "jira_auth",
+		"how to authenticate with jira: basic, token, cloud, or session (default basic)", &f.Jira.Auth)
+	f.add(fs, "jira-url", "jira_url", "the jira server URL", &f.Jira.URL)
+}
+
+// AddGithub adds the github flags.
+func (f *Flags) AddGithub(fs *flag.FlagSet) {
+	f.addSecret(fs, "github-token", "github_token", "github token", "the github API token", &f.Github.Token)
+}
+
+// AddBugzilla adds the bugzilla flags.
+func (f *Flags) AddBugzilla(fs *flag.FlagSet) {
+	f.add(fs, "bugzilla-url", "bugzilla_url", "the base URL for the bugzilla server", &f.Bugzilla.URL)
+	f.addSecret(fs, "bugzilla-token", "bugzilla_token", "bugzilla token",
+		"the bugzilla API token", &f.Bugzilla.Token)
+	f.add(fs, "bugzilla-auth", "bugzilla_auth",
+		"how to authenticate with bugzilla: header, query, or login (default header)", &f.Bugzilla.Auth)
+	f.add(fs, "bugzilla-user", "bugzilla_user", "the bugzilla login (with -bugzilla-auth login)", &f.Bugzilla.User)
+	f.addSecret(fs, "bugzilla-password", "bugzilla_password", "bugzilla password",
+		"the bugzilla password (with -bugzilla-auth login)", &f.Bugzilla.Password)
+}
+
+// AddAudit adds the audit log flag. Without one, the log is kept in
+// DefaultAuditLog.
+func (f *Flags) AddAudit(fs *flag.FlagSet) {
+	f.add(fs, "audit-log", "audit_log",
+		fmt.Sprintf("the file to append a JSON record of each change made in jira to, or %q (default %s)",
+			NoAuditLog, filepath.Join("$XDG_STATE_HOME", auditLogPath)),
+		&f.Audit.Log)
+	f.audit = true
+}
+
+// auditLogPath is the default audit log, relative to the state
+// directory.
+const auditLogPath = "jira-sync/audit.jsonl"
+
+// DefaultAuditLog returns the audit log used when none is given, in
+// $XDG_STATE_HOME or ~/.local/state. It returns an empty string if
+// neither can be found.
+func DefaultAuditLog() string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, auditLogPath)
+}
+
+// Resolve fills in the settings not given as flags from the
+// environment, then the base settings (which may be nil), then the
+// settings file. A secret is taken from the first of those places
+// that gives it in any form, so a password file named in the
+// environment wins over a password in the settings file.
+func (f *Flags) Resolve(base *Settings) error {
+	var fromBase map[string]string
+	if base != nil {
+		fromBase = base.values()
+	}
+
+	fromFile, err := f.loadFile()
+	if err != nil {
+		return err
+	}
+
+	for _, src := range f.sources {
+		fromFlags := map[string]string{
+			src.key:              *src.value,
+			src.key + "_file":    src.file,
+			src.key + "_command": src.command,
+		}
+		lookups := []func(string) string{
+			func(key string) string { return fromFlags[key] },
+			func(key string) string { return os.Getenv(envName(key)) },
+			func(key string) string { return fromBase[key] },
+			func(key string) string { return fromFile[key] },
+		}
+		if err := src.resolve(lookups); err != nil {
+			return err
+		}
+	}
+
+	if f.audit {
+		switch f.Audit.Log {
+		case "":
+			f.Audit.Log = DefaultAuditLog()
+		case NoAuditLog:
+			f.Audit.Log = ""
+		}
+	}
+	return nil
+}
+
+func (src *source) resolve(lookups []func(string) string) error {
+	for _, lookup := range lookups {
+		if value := lookup(src.key); value != "" {
+			*src.value = value
+			return nil
+		}
+		if !src.secret {
+			continue
+		}
+		if filename := lookup(src.key + "_file"); filename != "" {
+			value, err := readSecretFile(src.description, filename)
+			*src.value = value
+			return err
+		}
+		if command := lookup(src.key + "_command"); command != "" {
+			value, err := runSecretCommand(src.description, command)
+			*src.value = value
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *Flags) loadFile() (map[string]string, error) {
+	filename := f.file
+	if filename == "" {
+		filename = os.Getenv(SettingsFileEnv)
+	}
+	if filename != "" {
+		return readFile(filename)
+	}
+
+	// The default file is optional.
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, nil
+	}
+	filename = filepath.Join(home, DefaultSettingsFile[2:])
+	if _, err := os.Stat(filename); err != nil {
+		return nil, nil
+	}
+	return readFile(filename)
+}
+
+// values returns the settings by the names used in the shell syntax
+// settings file.
+func (s *Settings) values() map[string]string {
+	return map[string]string{
+		"jira_user":                 s.Jira.User,
+		"jira_password":             s.Jira.Password,
+		"jira_password_file":        s.Jira.PasswordFile,
+		"jira_password_command":     s.Jira.PasswordCommand,
+		"jira_url":                  s.Jira.URL,
+		"jira_auth":                 s.Jira.Auth,
+		"jira_token":                s.Jira.Token,
+		"jira_token_file":           s.Jira.TokenFile,
+		"jira_token_command":        s.Jira.TokenCommand,
+		"github_token":              s.Github.Token,
+		"github_token_file":         s.Github.TokenFile,
+		"github_token_command":      s.Github.TokenCommand,
+		"bugzilla_url":              s.Bugzilla.URL,
+		"bugzilla_token":            s.Bugzilla.Token,
+		"bugzilla_token_file":       s.Bugzilla.TokenFile,
+		"bugzilla_token_command":    s.Bugzilla.TokenCommand,
+		"bugzilla_auth":             s.Bugzilla.Auth,
+		"bugzilla_user":             s.Bugzilla.User,
+		"bugzilla_password":         s.Bugzilla.Password,
+		"bugzilla_password_file":    s.Bugzilla.PasswordFile,
+		"bugzilla_password_command": s.Bugzilla.PasswordCommand,
+		"audit_log":                 s.Audit.Log,
+	}
+}
+
+// ResolvePaths makes the names of secret files and the audit log
+// relative to dir, the directory of the file the settings were read
+// from.
+func (s *Settings) ResolvePaths(dir string) {
+	for _, path := range []*string{
+		&s.Jira.PasswordFile, &s.Jira.TokenFile, &s.Github.TokenFile,
+		&s.Bugzilla.TokenFile, &s.Bugzilla.PasswordFile, &s.Audit.Log,
+	} {
+		if *path != "" && *path != NoAuditLog && !filepath.IsAbs(*path) {
+			*path = filepath.Join(dir, *path)
+		}
+	}
+}
+
+// RequireJira checks that the jira settings were given.
+func (s *Settings) RequireJira() error {
+	return s.Jira.Require()
+}
+
+// credentials returns the jira credentials for the auth mode.
+func (j *Jira) credentials() jiraauth.Credentials {
+	creds := jiraauth.Credentials{
+		Mode:     jiraauth.Basic,
+		User:     j.User,
+		Password: j.Password,
+		Token:    j.Token,
+	}
+	if j.Auth != "" {
+		creds.Mode = jiraauth.Mode(j.Auth)
+	}
+	return creds
+}
+
+// Require checks that the settings needed by the auth mode were
+// given.
+func (j *Jira) Require() error {
+	creds := j.credentials()
+	switch creds.Mode {
+	case jiraauth.Basic, jiraauth.Session:
+		if j.User == "" || j.Password == "" {
+			return fmt.Errorf("Please specify both username (-jira-user) and password (-jira-password)")
+		}
+	case jiraauth.Token:
+		if j.Token == "" {
+			return fmt.Errorf("Please specify the personal access token (-jira-token)")
+		}
+	case jiraauth.Cloud:
+		if j.User == "" || j.Token == "" {
+			return fmt.Errorf("Please specify both email address (-jira-user) and API token (-jira-token)")
+		}
+	}
+	if err := creds.Validate(); err != nil {
+		return err
+	}
+	if j.URL == "" {
+		return fmt.Errorf("Please specify the -jira-url")
+	}
+	return nil
+}
+
+// Client creates a jira client using the auth mode. With a personal
+// access token and no -jira-user, the user is looked up on the server
+// so the commands know who they are acting as.
+func (j *Jira) Client() (*jira.Client, error) {
+	if err := j.Require(); err != nil {
+		return nil, err
+	}
+	httpClient, err := j.credentials().Client(j.URL, retry.New(nil))
+	if err != nil {
+		return nil, err
+	}
+	client, err := jira.NewClient(httpClient, j.URL)
+	if err != nil {
+		return nil, fmt.Errorf("Could not create jira client: %v", err)
+	}
+	if j.User == "" {
+		self, _, err := client.User.GetSelf()
+		if err != nil {
+			return nil, fmt.Errorf("Could not look up the jira user for the token: %v", err)
+		}
+		j.User = self.Name
+	}
+	return client, nil
+}
+
+// RequireGithub checks that the github settings were given.
+func (s *Settings) RequireGithub() error {
+	if s.Github.Token == "" {
+		return fmt.Errorf("Please provide an API token (-github-token)")
+	}
+	return nil
+}
+
+// JiraClient creates a jira client.
+func (s *Settings) JiraClient() (*jira.Client, error) {
+	return s.Jira.Client()
+}
+
+// JiraBackend creates a jira client and wraps it in the backend for
+// the kind of server.
+func (s *Settings) JiraBackend() (jirabackend.Backend, error) {
+	return s.Jira.Backend()
+}
+
+// Backend creates a jira client and wraps it in the backend for the
+// kind of server. The cloud auth mode means the server is Jira Cloud.
+func (j *Jira) Backend() (jirabackend.Backend, error) {
+	client, err := j.Client()
+	if err != nil {
+		return nil, err
+	}
+	cloud := j.credentials().Mode == jiraauth.Cloud
+	return jirabackend.New(client, cloud, j.User), nil
+}
+
+// GithubHTTPClient creates an HTTP client that authenticates with the
+// github token. Callers wrap it in the version of the github client
+// they use.
+func (s *Settings) GithubHTTPClient() (*http.Client, error) {
+	if err := s.RequireGithub(); err != nil {
+		return nil, err
+	}
+	ts := oauth2.StaticTokenSource(
+		&oauth2.Token{AccessToken: s.Github.Token},
+	)
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient,
+		&http.Client{Transport: retry.New(nil)})
+	return oauth2.NewClient(ctx, ts), nil
+}
+
+// BugzillaClient creates a bugzilla client. If anonymous is true and
+// no credentials were given, the client does not authenticate.
+func (s *Settings) BugzillaClient(anonymous bool) (*bugzilla.Client, error) {
+	if s.Bugzilla.URL == "" {
+		return nil, fmt.Errorf("Please specify the -bugzilla-url")
+	}
+	creds := bugzilla.Credentials{
+		Mode:     bugzilla.AuthHeader,
+		APIKey:   s.Bugzilla.Token,
+		User:     s.Bugzilla.User,
+		Password: s.Bugzilla.Password,
+	}
+	if s.Bugzilla.Auth != "" {
+		creds.Mode = bugzilla.AuthMode(s.Bugzilla.Auth)
+	}
+	if anonymous && creds.APIKey == "" && creds.User == "" && creds.Password == "" {
+		creds.Mode = bugzilla.AuthNone
+	}
+	client, err := bugzilla.NewClient(s.Bugzilla.URL, creds)
+	if err != nil {
+		return nil, fmt.Errorf("Please provide the bugzilla credentials (-bugzilla-token or -bugzilla-user and -bugzilla-password): %v", err)
+	}
+	return client, nil
+}
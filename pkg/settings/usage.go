@@ -0,0 +1,45 @@
+package settings
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// Program is the name of the binary the commands are run from.
+const Program = "jira-sync"
+
+// ExitFailed is the exit code of a command that ran but could not
+// handle some of the items it was given, for example tickets that
+// could not be created. Commands that cannot run at all, because of
+// bad options, settings, or credentials, exit with 1.
+const ExitFailed = 2
+
+// NewFlagSet creates the flag set for a command, with help text in
+// the same form for every command. Parse errors are returned rather
+// than exiting, so that commands can give them the exit code for bad
+// options with ParseExit.
+func NewFlagSet(name, synopsis, description string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		out := fs.Output()
+		fmt.Fprintf(out, "Usage: %s %s [options] %s\n\n%s\n\nOptions:\n",
+			Program, name, synopsis, description)
+		fs.PrintDefaults()
+		fmt.Fprintf(out, "\nServer settings not given as options are read from the environment,\n"+
+			"then from the -settings file.\n")
+	}
+	fs.SetOutput(os.Stderr)
+	return fs
+}
+
+// ParseExit returns the exit code for an error from parsing the flags,
+// which the flag set has already reported. Asking for help is not an
+// error.
+func ParseExit(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	return 1
+}
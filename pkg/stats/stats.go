@@ -0,0 +1,110 @@
+// Package stats counts what happened to the upstream tickets seen
+// during a run, for the summary at the end.
+package stats
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+// Counts holds the totals for one import. The zero value is ready to
+// use.
+type Counts struct {
+	mutex    sync.Mutex
+	seen     int
+	created  int
+	existing int
+
+	// Failures lists the upstream tickets that could not be
+	// imported.
+	Failures Failures
+}
+
+// Record counts one upstream ticket and whether a jira ticket was
+// created for it.
+func (c *Counts) Record(created bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.seen++
+	if created {
+		c.created++
+	} else {
+		c.existing++
+	}
+}
+
+// Fail counts one upstream ticket that could not be imported.
+func (c *Counts) Fail(item string, err error) {
+	c.mutex.Lock()
+	c.seen++
+	c.mutex.Unlock()
+	c.Failures.Add(item, err)
+}
+
+// String summarizes the counts.
+func (c *Counts) String() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	summary := fmt.Sprintf("%d seen, %d created, %d existing", c.seen, c.created, c.existing)
+	if failed := c.Failures.Len(); failed != 0 {
+		summary += fmt.Sprintf(", %d failed", failed)
+	}
+	return summary
+}
+
+// Failure is an item that could not be handled.
+type Failure struct {
+	Item string
+	Err  error
+}
+
+// Failures collects the items that could not be handled, so that a
+// command can carry on with the rest and report them all at the end.
+// The zero value is ready to use.
+type Failures struct {
+	mutex sync.Mutex
+	list  []Failure
+}
+
+// Add records a failure.
+func (f *Failures) Add(item string, err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.list = append(f.list, Failure{Item: item, Err: err})
+}
+
+// Len returns the number of failures.
+func (f *Failures) Len() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return len(f.list)
+}
+
+// List returns the failures in the order they happened.
+func (f *Failures) List() []Failure {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]Failure(nil), f.list...)
+}
+
+// Show prints a table of the failures, if there were any.
+func (f *Failures) Show() {
+	list := f.List()
+	if len(list) == 0 {
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "\nFAILED\tERROR\n")
+	for _, failure := range list {
+		fmt.Fprintf(w, "%s\t%s\n", failure.Item, OneLine(failure.Err.Error()))
+	}
+	w.Flush()
+}
+
+// OneLine joins the lines of an error message, so it fits in a table.
+func OneLine(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
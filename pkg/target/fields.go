@@ -0,0 +1,212 @@
+package target
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/trivago/tgo/tcontainer"
+	"gopkg.in/yaml.v2"
+
+	"github.com/openshift-metal3/jira-sync/pkg/render"
+	"github.com/openshift-metal3/jira-sync/pkg/usermap"
+)
+
+// customField is a field of new tickets set by name from the options,
+// resolved against the create metadata.
+type customField struct {
+	id    string
+	name  string
+	kind  string
+	items string
+	value *template.Template
+}
+
+type fieldsFile struct {
+	Fields map[string]string `yaml:"fields"`
+}
+
+// LoadFields reads a YAML file of field values of the form
+//
+//	fields:
+//	  Story Points: 3
+//	  Team: Metal3
+//	  Git Pull Request: '{{.URL}}'
+func LoadFields(filename string) (map[string]string, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	parsed := fieldsFile{}
+	if err := yaml.UnmarshalStrict(content, &parsed); err != nil {
+		return nil, fmt.Errorf("Unable to parse fields %s: %s", filename, err)
+	}
+	return parsed.Fields, nil
+}
+
+// resolveFields finds the field IDs for the names in the options and
+// parses their values, which are templates expanded with the item for
+// each new ticket.
+func resolveFields(project *jira.MetaProject, issueType *jira.MetaIssueType, values map[string]string) ([]*customField, error) {
+	names := []string{}
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	results := []*customField{}
+	for _, name := range names {
+		field, err := findField(project, issueType, name)
+		if err != nil {
+			return nil, err
+		}
+		value := values[name]
+
+		// Literal values can be checked now, templates only once
+		// they are expanded.
+		if !strings.Contains(value, "{{") {
+			if _, err := field.convert(value); err != nil {
+				return nil, err
+			}
+			allowed := allowedValues(issueType.Fields, field.id)
+			if len(allowed) != 0 {
+				spelled := []string{}
+				for _, v := range field.split(value) {
+					match, ok := findFold(allowed, v)
+					if !ok {
+						return nil, fmt.Errorf("unknown value %q for field %s in %s, choose one of: %s",
+							v, field.name, project.Key, choices(allowed))
+					}
+					spelled = append(spelled, match)
+				}
+				value = strings.Join(spelled, ", ")
+			}
+		}
+
+		field.value, err = render.Parse(field.name, value)
+		if err != nil {
+			return nil, fmt.Errorf("bad value for field %s: %s", field.name, err)
+		}
+		results = append(results, field)
+	}
+	return results, nil
+}
+
+// findField looks up a field by name, ignoring case, or by ID.
+func findField(project *jira.MetaProject, issueType *jira.MetaIssueType, name string) (*customField, error) {
+	matches := []*customField{}
+	all := []string{}
+	for id, f := range issueType.Fields {
+		meta, ok := f.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		fieldName, _ := meta["name"].(string)
+		all = append(all, fieldName)
+		if !strings.EqualFold(fieldName, name) && id != name {
+			continue
+		}
+		field := &customField{id: id, name: fieldName}
+		if schema, ok := meta["schema"].(map[string]interface{}); ok {
+			field.kind, _ = schema["type"].(string)
+			field.items, _ = schema["items"].(string)
+		}
+		matches = append(matches, field)
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("unknown field %q for issue type %s in %s, choose one of: %s",
+			name, issueType.Name, project.Key, choices(all))
+	case 1:
+		return matches[0], nil
+	}
+	ids := []string{}
+	for _, field := range matches {
+		ids = append(ids, field.id)
+	}
+	return nil, fmt.Errorf("more than one field is called %q in %s, use one of the IDs: %s",
+		name, project.Key, choices(ids))
+}
+
+// setFields expands the field values for the item.
+func setFields(fields *jira.IssueFields, custom []*customField, item *render.Item) error {
+	if item == nil {
+		item = &render.Item{}
+	}
+	for _, field := range custom {
+		text, err := render.Execute(field.value, item)
+		if err != nil {
+			return err
+		}
+		// An empty value leaves the field unset, so templates can
+		// skip items without the data.
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		value, err := field.convert(text)
+		if err != nil {
+			return err
+		}
+		if fields.Unknowns == nil {
+			fields.Unknowns = tcontainer.NewMarshalMap()
+		}
+		fields.Unknowns[field.id] = value
+	}
+	return nil
+}
+
+// split returns the comma separated values of an array field, or the
+// whole value of any other field.
+func (field *customField) split(text string) []string {
+	if field.kind != "array" {
+		return []string{strings.TrimSpace(text)}
+	}
+	results := []string{}
+	for _, v := range strings.Split(text, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			results = append(results, v)
+		}
+	}
+	return results
+}
+
+// convert builds the value jira expects for the field type.
+func (field *customField) convert(text string) (interface{}, error) {
+	if field.kind != "array" {
+		return field.convertOne(field.kind, strings.TrimSpace(text))
+	}
+	results := []interface{}{}
+	for _, v := range field.split(text) {
+		value, err := field.convertOne(field.items, v)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, value)
+	}
+	return results, nil
+}
+
+func (field *customField) convertOne(kind, text string) (interface{}, error) {
+	switch kind {
+	case "string", "date", "datetime", "any", "":
+		return text, nil
+	case "number":
+		n, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("field %s needs a number, not %q", field.name, text)
+		}
+		return n, nil
+	case "option":
+		return map[string]string{"value": text}, nil
+	case "version", "priority", "group", "component":
+		return map[string]string{"name": text}, nil
+	case "user":
+		return usermap.ParseUser(text), nil
+	}
+	return nil, fmt.Errorf("field %s has type %s, which cannot be set", field.name, kind)
+}
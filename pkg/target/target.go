@@ -0,0 +1,480 @@
+// Package target describes where new jira tickets are created and
+// checks those settings against the project's create metadata before
+// anything is written.
+package target
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/trivago/tgo/tcontainer"
+
+	"github.com/openshift-metal3/jira-sync/pkg/jirabackend"
+	"github.com/openshift-metal3/jira-sync/pkg/render"
+	"github.com/openshift-metal3/jira-sync/pkg/usermap"
+)
+
+// Options are the settings given by the user for new tickets.
+type Options struct {
+	Project   string
+	IssueType string
+	Component string
+	Epic      string
+	Parent    string
+	Labels    []string
+	Assignee  string
+	Priority  string
+	// Fields maps the names of other fields, usually custom fields,
+	// to values for new tickets. The values are templates expanded
+	// with the render.Item the ticket is created from.
+	Fields map[string]string
+}
+
+// Target is a set of Options that has been checked against the create
+// metadata for the project.
+type Target struct {
+	Options
+
+	// IssueTypeName is the name of the issue type as jira spells it.
+	IssueTypeName string
+
+	epicLinkField      string
+	parentAllowed      bool
+	assignee           *jira.User
+	reporterAllowed    bool
+	fixVersionsAllowed bool
+	fields             []*customField
+}
+
+// ParseLabels splits a comma separated list of labels given on the
+// command line.
+func ParseLabels(labels string) []string {
+	results := []string{}
+	for _, label := range strings.Split(labels, ",") {
+		label = strings.TrimSpace(label)
+		if label != "" {
+			results = append(results, label)
+		}
+	}
+	return results
+}
+
+// FetchMeta gets the create metadata for the project. If the user
+// cannot create tickets in the project, the error lists the projects
+// they can create tickets in.
+func FetchMeta(client *jira.Client, project string) (*jira.CreateMetaInfo, error) {
+	meta, resp, err := client.Issue.GetCreateMeta(project)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch metadata for %s: %s",
+			project, jira.NewJiraError(resp, err))
+	}
+	if meta.GetProjectWithKey(project) != nil {
+		return meta, nil
+	}
+
+	// Without the fields the list of every project is small enough
+	// to ask for.
+	all, resp, err := client.Issue.GetCreateMetaWithOptions(&jira.GetQueryOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("unknown project %q, and could not list the projects: %s",
+			project, jira.NewJiraError(resp, err))
+	}
+	keys := []string{}
+	for _, p := range all.Projects {
+		keys = append(keys, p.Key)
+	}
+	return nil, fmt.Errorf("unknown project %q, choose one of: %s", project, choices(keys))
+}
+
+// Resolve checks the options against the create metadata and returns
+// a Target that can be applied to new tickets. The error describes the
+// valid choices for any setting that is wrong, or the required fields
+// that new tickets would be missing.
+func Resolve(meta *jira.CreateMetaInfo, opts Options) (*Target, error) {
+	project := meta.GetProjectWithKey(opts.Project)
+	if project == nil {
+		keys := []string{}
+		for _, p := range meta.Projects {
+			keys = append(keys, p.Key)
+		}
+		return nil, fmt.Errorf("unknown project %q, choose one of: %s",
+			opts.Project, choices(keys))
+	}
+
+	issueType := project.GetIssueTypeWithName(opts.IssueType)
+	if issueType == nil {
+		return nil, fmt.Errorf("unknown issue type %q in %s, choose one of: %s",
+			opts.IssueType, project.Key, choices(issueTypeNames(project, nil)))
+	}
+
+	result := &Target{
+		Options:       opts,
+		IssueTypeName: issueType.Name,
+		parentAllowed: allowsParent(issueType),
+	}
+
+	fields, err := issueType.GetAllFields()
+	if err != nil {
+		return nil, fmt.Errorf("could not read fields for %s in %s: %s",
+			issueType.Name, project.Key, err)
+	}
+
+	if opts.Component != "" {
+		if _, ok := issueType.Fields["components"]; !ok {
+			return nil, fmt.Errorf("issue type %s in %s does not have components",
+				issueType.Name, project.Key)
+		}
+		allowed := allowedValues(issueType.Fields, "components")
+		name, ok := findFold(allowed, opts.Component)
+		if !ok {
+			return nil, fmt.Errorf("unknown component %q in %s, choose one of: %s",
+				opts.Component, project.Key, choices(allowed))
+		}
+		result.Component = name
+	}
+
+	result.epicLinkField = fields["Epic Link"]
+	if opts.Epic != "" && result.epicLinkField == "" {
+		return nil, fmt.Errorf("issue type %s in %s cannot be linked to an epic",
+			issueType.Name, project.Key)
+	}
+
+	if opts.Parent != "" {
+		if !result.parentAllowed {
+			return nil, fmt.Errorf(
+				"issue type %s in %s cannot have a parent, choose one of: %s",
+				issueType.Name, project.Key,
+				choices(issueTypeNames(project, allowsParent)))
+		}
+		if opts.Epic != "" {
+			return nil, fmt.Errorf("tickets inherit the epic of their parent, so do not give both")
+		}
+	} else if issueType.Subtasks {
+		return nil, fmt.Errorf("issue type %s in %s is a sub-task type and needs a parent",
+			issueType.Name, project.Key)
+	}
+
+	if len(opts.Labels) != 0 {
+		if _, ok := issueType.Fields["labels"]; !ok {
+			return nil, fmt.Errorf("issue type %s in %s does not have labels",
+				issueType.Name, project.Key)
+		}
+	}
+
+	if opts.Priority != "" {
+		if _, ok := issueType.Fields["priority"]; !ok {
+			return nil, fmt.Errorf("issue type %s in %s does not have a priority",
+				issueType.Name, project.Key)
+		}
+		allowed := allowedValues(issueType.Fields, "priority")
+		name, ok := findFold(allowed, opts.Priority)
+		if !ok {
+			return nil, fmt.Errorf("unknown priority %q in %s, choose one of: %s",
+				opts.Priority, project.Key, choices(allowed))
+		}
+		result.Priority = name
+	}
+
+	_, result.reporterAllowed = issueType.Fields["reporter"]
+	_, result.fixVersionsAllowed = issueType.Fields["fixVersions"]
+
+	if opts.Assignee != "" {
+		if _, ok := issueType.Fields["assignee"]; !ok {
+			return nil, fmt.Errorf("issue type %s in %s cannot be assigned when created",
+				issueType.Name, project.Key)
+		}
+	}
+
+	result.fields, err = resolveFields(project, issueType, opts.Fields)
+	if err != nil {
+		return nil, err
+	}
+
+	if missing := result.missingFields(issueType.Fields); len(missing) != 0 {
+		return nil, fmt.Errorf("issue type %s in %s requires fields that new tickets would not have: %s",
+			issueType.Name, project.Key, choices(missing))
+	}
+
+	return result, nil
+}
+
+// missingFields returns the names of the required fields without a
+// default that new tickets would not have.
+func (t *Target) missingFields(fields tcontainer.MarshalMap) []string {
+	missing := []string{}
+	for id, f := range fields {
+		field, ok := f.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		required, _ := field["required"].(bool)
+		hasDefault, _ := field["hasDefaultValue"].(bool)
+		if !required || hasDefault || t.sets(id) {
+			continue
+		}
+		name, ok := field["name"].(string)
+		if !ok {
+			name = id
+		}
+		missing = append(missing, name)
+	}
+	return missing
+}
+
+// sets returns true if new tickets have a value for the field.
+func (t *Target) sets(fieldID string) bool {
+	switch fieldID {
+	case "project", "issuetype", "summary", "description", "reporter":
+		return true
+	case "components":
+		return t.Component != ""
+	case "labels":
+		return len(t.Labels) != 0
+	case "assignee":
+		return t.Assignee != ""
+	case "priority":
+		return t.Priority != ""
+	case "parent":
+		return t.Parent != ""
+	case t.epicLinkField:
+		return t.Epic != ""
+	}
+	for _, field := range t.fields {
+		if field.id == fieldID {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsReporter returns true if the reporter can be set when
+// creating tickets.
+func (t *Target) AllowsReporter() bool {
+	return t.reporterAllowed
+}
+
+// AllowsFixVersions returns true if the fix versions can be set when
+// creating tickets.
+func (t *Target) AllowsFixVersions() bool {
+	return t.fixVersionsAllowed
+}
+
+// AllowsEpic returns true if new tickets can be linked to an epic.
+func (t *Target) AllowsEpic() bool {
+	return t.epicLinkField != ""
+}
+
+// CheckComponent makes sure the component exists in the project, and
+// returns its name as jira spells it.
+func CheckComponent(meta *jira.CreateMetaInfo, projectKey, component string) (string, error) {
+	project := meta.GetProjectWithKey(projectKey)
+	if project == nil {
+		return "", fmt.Errorf("unknown project %q", projectKey)
+	}
+	allowed := []string{}
+	for _, issueType := range project.IssueTypes {
+		for _, name := range allowedValues(issueType.Fields, "components") {
+			if !containsFold(allowed, name) {
+				allowed = append(allowed, name)
+			}
+		}
+	}
+	name, ok := findFold(allowed, component)
+	if !ok {
+		return "", fmt.Errorf("unknown component %q in %s, choose one of: %s",
+			component, project.Key, choices(allowed))
+	}
+	return name, nil
+}
+
+// AllowsParent returns true if new tickets can have a parent.
+func (t *Target) AllowsParent() bool {
+	return t.parentAllowed
+}
+
+// allowsParent returns true if tickets of the issue type can have a
+// parent. On Jira Server only sub-tasks can, while the Jira Cloud issue
+// hierarchy lets other types be put under an epic, and says so with a
+// parent field in the create metadata.
+func allowsParent(issueType *jira.MetaIssueType) bool {
+	_, ok := issueType.Fields["parent"]
+	return ok || issueType.Subtasks
+}
+
+// CheckEpic makes sure the ticket with the key exists and is an epic.
+func CheckEpic(client *jira.Client, key string) error {
+	epic, _, err := client.Issue.Get(key, &jira.GetQueryOptions{Fields: "issuetype"})
+	if err != nil {
+		return fmt.Errorf("could not find epic %s: %s", key, err)
+	}
+	if !strings.EqualFold(epic.Fields.Type.Name, "epic") {
+		return fmt.Errorf("%s is a %s, not an epic", key, epic.Fields.Type.Name)
+	}
+	return nil
+}
+
+// CheckParent makes sure the ticket with the key exists and can have
+// children.
+func CheckParent(client *jira.Client, key string) error {
+	parent, _, err := client.Issue.Get(key, &jira.GetQueryOptions{Fields: "issuetype"})
+	if err != nil {
+		return fmt.Errorf("could not find parent %s: %s", key, err)
+	}
+	if parent.Fields.Type.Subtask {
+		return fmt.Errorf("%s is a sub-task and cannot be a parent", key)
+	}
+	return nil
+}
+
+// CheckReferences looks up the epic, parent, and assignee to make
+// sure they exist, since the create metadata cannot tell us that. The
+// assignee found is used for new tickets, so that Jira Cloud gets the
+// account ID it needs even if the assignee was given by name.
+func (t *Target) CheckReferences(backend jirabackend.Backend) error {
+	client := backend.Client()
+	if t.Epic != "" {
+		if err := CheckEpic(client, t.Epic); err != nil {
+			return err
+		}
+	}
+	if t.Parent != "" {
+		if err := CheckParent(client, t.Parent); err != nil {
+			return err
+		}
+	}
+	if t.Assignee != "" {
+		assignee, err := backend.FindUser(usermap.ParseUser(t.Assignee))
+		if err != nil {
+			return fmt.Errorf("could not find assignee %s: %s", t.Assignee, err)
+		}
+		t.assignee = assignee
+	}
+	return nil
+}
+
+// Apply fills in the fields for a new ticket created from the item.
+// Labels are added to any already present in the fields.
+func (t *Target) Apply(fields *jira.IssueFields, item *render.Item) error {
+	fields.Project = jira.Project{
+		Key: t.Project,
+	}
+	fields.Type = jira.IssueType{
+		Name: t.IssueTypeName,
+	}
+	if t.Component != "" {
+		fields.Components = []*jira.Component{
+			&jira.Component{
+				Name: t.Component,
+			},
+		}
+	}
+	fields.Labels = append(fields.Labels, t.Labels...)
+	if t.assignee != nil {
+		fields.Assignee = &jira.User{
+			Name:      t.assignee.Name,
+			AccountID: t.assignee.AccountID,
+		}
+	} else if t.Assignee != "" {
+		fields.Assignee = usermap.ParseUser(t.Assignee)
+	}
+	if t.Priority != "" {
+		fields.Priority = &jira.Priority{
+			Name: t.Priority,
+		}
+	}
+	if t.Parent != "" {
+		fields.Parent = &jira.Parent{
+			Key: t.Parent,
+		}
+	}
+	if t.Epic != "" {
+		t.SetEpic(fields, t.Epic)
+	}
+	return setFields(fields, t.fields, item)
+}
+
+// SetEpic links a new ticket to the epic with the key, replacing any
+// epic already set. It does nothing if the issue type cannot be linked
+// to an epic.
+func (t *Target) SetEpic(fields *jira.IssueFields, key string) {
+	if t.epicLinkField == "" {
+		return
+	}
+	if fields.Unknowns == nil {
+		fields.Unknowns = tcontainer.NewMarshalMap()
+	}
+	fields.Unknowns[t.epicLinkField] = key
+}
+
+// ClearEpic removes the epic link from a new ticket.
+func (t *Target) ClearEpic(fields *jira.IssueFields) {
+	if t.epicLinkField != "" && fields.Unknowns != nil {
+		delete(fields.Unknowns, t.epicLinkField)
+	}
+}
+
+func issueTypeNames(project *jira.MetaProject, filter func(*jira.MetaIssueType) bool) []string {
+	names := []string{}
+	for _, t := range project.IssueTypes {
+		if filter == nil || filter(t) {
+			names = append(names, t.Name)
+		}
+	}
+	return names
+}
+
+// allowedValues returns the names of the values a field accepts.
+func allowedValues(fields tcontainer.MarshalMap, fieldID string) []string {
+	results := []string{}
+	field, ok := fields[fieldID].(map[string]interface{})
+	if !ok {
+		return results
+	}
+	values, ok := field["allowedValues"].([]interface{})
+	if !ok {
+		return results
+	}
+	for _, v := range values {
+		value, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, key := range []string{"name", "value"} {
+			if name, ok := value[key].(string); ok {
+				results = append(results, name)
+				break
+			}
+		}
+	}
+	return results
+}
+
+func containsFold(values []string, s string) bool {
+	_, ok := findFold(values, s)
+	return ok
+}
+
+// findFold returns the value matching s, as it is spelled in values.
+func findFold(values []string, s string) (string, bool) {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+func choices(values []string) string {
+	if len(values) == 0 {
+		return "(none)"
+	}
+	sorted := append([]string{}, values...)
+	sort.Strings(sorted)
+	quoted := make([]string, len(sorted))
+	for i, v := range sorted {
+		quoted[i] = fmt.Sprintf("%q", v)
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,151 @@
+// Package usermap translates github logins into jira users.
+package usermap
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/andygrunwald/go-jira"
+	"gopkg.in/yaml.v2"
+)
+
+// accountIDPrefix marks values in the mapping file that are jira
+// cloud account IDs instead of usernames.
+const accountIDPrefix = "accountid:"
+
+// Map holds the mapping from github logins to jira users, and
+// remembers the logins it could not translate. A nil Map is valid and
+// maps nobody.
+type Map struct {
+	users map[string]string
+
+	mutex    sync.Mutex
+	unmapped map[string]bool
+}
+
+type mapFile struct {
+	Users map[string]string `yaml:"users"`
+}
+
+// Load reads a YAML mapping file of the form
+//
+//	users:
+//	  github-login: jira-username
+//	  other-login: accountid:5b10a2844c20165700ede21g
+func Load(filename string) (*Map, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed := mapFile{}
+	if err := yaml.Unmarshal(content, &parsed); err != nil {
+		return nil, fmt.Errorf("Unable to parse user map %s: %s", filename, err)
+	}
+
+	result := &Map{
+		users:    make(map[string]string),
+		unmapped: make(map[string]bool),
+	}
+	for login, user := range parsed.Users {
+		if user == "" {
+			return nil, fmt.Errorf("No jira user given for %s in %s", login, filename)
+		}
+		// github logins are not case sensitive
+		result.users[strings.ToLower(login)] = user
+	}
+	return result, nil
+}
+
+func (m *Map) lookup(login string) (string, bool) {
+	if m == nil || login == "" {
+		return "", false
+	}
+	user, ok := m.users[strings.ToLower(login)]
+	if !ok {
+		m.mutex.Lock()
+		m.unmapped[login] = true
+		m.mutex.Unlock()
+	}
+	return user, ok
+}
+
+// JiraUser returns the jira user for the github login, or nil if the
+// login is not mapped.
+func (m *Map) JiraUser(login string) *jira.User {
+	user, ok := m.lookup(login)
+	if !ok {
+		return nil
+	}
+	return ParseUser(user)
+}
+
+// ParseUser returns the jira user for a username, or an account ID
+// with the accountid: prefix.
+func ParseUser(user string) *jira.User {
+	if strings.HasPrefix(strings.ToLower(user), accountIDPrefix) {
+		return &jira.User{AccountID: user[len(accountIDPrefix):]}
+	}
+	return &jira.User{Name: user}
+}
+
+// FormatUser returns the user in the form ParseUser reads.
+func FormatUser(user *jira.User) string {
+	if user.Name == "" && user.AccountID != "" {
+		return accountIDPrefix + user.AccountID
+	}
+	return user.Name
+}
+
+// UserName returns the username or account ID of the jira user, for
+// messages.
+func UserName(user *jira.User) string {
+	if user.Name != "" {
+		return user.Name
+	}
+	return user.AccountID
+}
+
+// Mention returns the jira markup to mention the user with the github
+// login, or the original github mention if the login is not mapped.
+func (m *Map) Mention(login string) string {
+	user, ok := m.lookup(login)
+	if !ok {
+		return "@" + login
+	}
+	if strings.HasPrefix(strings.ToLower(user), accountIDPrefix) {
+		return fmt.Sprintf("[~accountid:%s]", user[len(accountIDPrefix):])
+	}
+	return fmt.Sprintf("[~%s]", user)
+}
+
+// SameUser returns true if the two jira users refer to the same
+// person.
+func SameUser(a, b *jira.User) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.AccountID != "" || b.AccountID != "" {
+		return a.AccountID == b.AccountID
+	}
+	return strings.EqualFold(a.Name, b.Name)
+}
+
+// Unmapped returns the sorted list of github logins seen that are not
+// in the map.
+func (m *Map) Unmapped() []string {
+	if m == nil {
+		return nil
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	results := []string{}
+	for login := range m.unmapped {
+		results = append(results, login)
+	}
+	sort.Strings(results)
+	return results
+}
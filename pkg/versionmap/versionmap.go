@@ -0,0 +1,189 @@
+// Package versionmap translates github milestones into jira fix
+// versions.
+package versionmap
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andygrunwald/go-jira"
+	"gopkg.in/yaml.v2"
+
+	"github.com/openshift-metal3/jira-sync/pkg/jirabackend"
+)
+
+// Map holds the mapping from github milestones to jira fix versions,
+// along with the versions that exist in the jira project. A nil Map is
+// valid and maps nothing.
+type Map struct {
+	versions map[string]string
+	managed  map[string]bool
+
+	backend   jirabackend.Backend
+	projectID int
+	create    bool
+
+	mutex    sync.Mutex
+	existing map[string]string
+	unmapped map[string]bool
+}
+
+type mapFile struct {
+	Milestones map[string]string `yaml:"milestones"`
+}
+
+// Load reads a YAML mapping file of the form
+//
+//	milestones:
+//	  v1.0: metal3 1.0
+//	  "4.6": OpenShift 4.6
+func Load(filename string) (*Map, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed := mapFile{}
+	if err := yaml.Unmarshal(content, &parsed); err != nil {
+		return nil, fmt.Errorf("Unable to parse milestone map %s: %s", filename, err)
+	}
+
+	result := &Map{
+		versions: make(map[string]string),
+		managed:  make(map[string]bool),
+		existing: make(map[string]string),
+		unmapped: make(map[string]bool),
+	}
+	for milestone, version := range parsed.Milestones {
+		if version == "" {
+			return nil, fmt.Errorf("No jira version given for %s in %s", milestone, filename)
+		}
+		result.versions[strings.ToLower(milestone)] = version
+		result.managed[strings.ToLower(version)] = true
+	}
+	return result, nil
+}
+
+// Connect loads the versions that already exist in the jira project.
+// If create is true, versions named in the map that do not exist are
+// created the first time they are needed.
+func (m *Map) Connect(backend jirabackend.Backend, projectKey string, create bool) error {
+	if m == nil {
+		return nil
+	}
+	project, _, err := backend.Client().Project.Get(projectKey)
+	if err != nil {
+		return fmt.Errorf("could not get versions of %s: %s", projectKey, err)
+	}
+	m.projectID, err = strconv.Atoi(project.ID)
+	if err != nil {
+		return fmt.Errorf("could not convert project ID %q to integer: %s", project.ID, err)
+	}
+	m.backend = backend
+	m.create = create
+	for _, version := range project.Versions {
+		m.existing[strings.ToLower(version.Name)] = version.Name
+	}
+	return nil
+}
+
+// Missing returns the sorted list of versions named in the map that
+// do not exist in the jira project. Call Connect first.
+func (m *Map) Missing() []string {
+	if m == nil {
+		return nil
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	seen := make(map[string]bool)
+	results := []string{}
+	for _, version := range m.versions {
+		key := strings.ToLower(version)
+		if _, ok := m.existing[key]; !ok && !seen[key] {
+			seen[key] = true
+			results = append(results, version)
+		}
+	}
+	sort.Strings(results)
+	return results
+}
+
+// Existing returns the sorted list of versions in the jira project.
+func (m *Map) Existing() []string {
+	if m == nil {
+		return nil
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	results := []string{}
+	for _, name := range m.existing {
+		results = append(results, name)
+	}
+	sort.Strings(results)
+	return results
+}
+
+// Version returns the name of the jira fix version for the milestone,
+// or an empty string if the milestone is not mapped. If the version
+// does not exist in the project it is created when allowed, and
+// otherwise an error is returned.
+func (m *Map) Version(milestone string) (string, error) {
+	if m == nil || milestone == "" {
+		return "", nil
+	}
+	version, ok := m.versions[strings.ToLower(milestone)]
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if !ok {
+		m.unmapped[milestone] = true
+		return "", nil
+	}
+	if name, ok := m.existing[strings.ToLower(version)]; ok {
+		return name, nil
+	}
+	if !m.create {
+		return "", fmt.Errorf("version %q for milestone %q does not exist", version, milestone)
+	}
+
+	created, err := m.backend.CreateVersion(&jira.Version{
+		Name:      version,
+		ProjectID: m.projectID,
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not create version %q: %s", version, err)
+	}
+	fmt.Printf("  CREATED VERSION %s\n", created.Name)
+	m.existing[strings.ToLower(created.Name)] = created.Name
+	return created.Name, nil
+}
+
+// Managed returns true if the version is one of the targets of the
+// map, and so may be replaced when a milestone changes.
+func (m *Map) Managed(version string) bool {
+	if m == nil {
+		return false
+	}
+	return m.managed[strings.ToLower(version)]
+}
+
+// Unmapped returns the sorted list of milestones seen that are not in
+// the map.
+func (m *Map) Unmapped() []string {
+	if m == nil {
+		return nil
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	results := []string{}
+	for milestone := range m.unmapped {
+		results = append(results, milestone)
+	}
+	sort.Strings(results)
+	return results
+}
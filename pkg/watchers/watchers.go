@@ -0,0 +1,151 @@
+// Package watchers decides who watches the tickets the importers
+// create.
+package watchers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	"gopkg.in/yaml.v2"
+
+	"github.com/openshift-metal3/jira-sync/pkg/jirabackend"
+	"github.com/openshift-metal3/jira-sync/pkg/usermap"
+)
+
+// Policy lists the watchers to add to new tickets. Jira makes the
+// user creating a ticket a watcher, and unless KeepCreator is set
+// that watch is removed, assuming the user is either a bot or someone
+// who does not want to see all notifications for all of the items
+// they import. A nil Policy adds nobody and removes the creator.
+type Policy struct {
+	// KeepCreator leaves the creating user watching new tickets.
+	KeepCreator bool `yaml:"keep-creator"`
+	// Watchers are added to every new ticket.
+	Watchers []string `yaml:"watchers"`
+	// Components maps component names to the watchers to add to
+	// tickets in the component.
+	Components map[string][]string `yaml:"components"`
+}
+
+// Load reads a YAML watchers file of the form
+//
+//	keep-creator: false
+//	watchers:
+//	- team-lead
+//	components:
+//	  KNI Deploy Install:
+//	  - janedoe
+//	  - accountid:5b10a2844c20165700ede21g
+func Load(filename string) (*Policy, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Policy{}
+	if err := yaml.UnmarshalStrict(content, p); err != nil {
+		return nil, fmt.Errorf("Unable to parse watchers %s: %s", filename, err)
+	}
+	for _, user := range p.Watchers {
+		if user == "" {
+			return nil, fmt.Errorf("Empty watcher in %s", filename)
+		}
+	}
+	for component, users := range p.Components {
+		for _, user := range users {
+			if user == "" {
+				return nil, fmt.Errorf("Empty watcher for component %s in %s", component, filename)
+			}
+		}
+	}
+	return p, nil
+}
+
+// New loads the policy from the file, if one is given, and keeps the
+// creator watching if either the file or keepCreator says to.
+func New(filename string, keepCreator bool) (*Policy, error) {
+	p := &Policy{}
+	if filename != "" {
+		var err error
+		if p, err = Load(filename); err != nil {
+			return nil, err
+		}
+	}
+	p.KeepCreator = p.KeepCreator || keepCreator
+	return p, nil
+}
+
+// For returns the watchers for a new ticket in the components, with
+// the extra watchers given by a routing rule, without duplicates.
+func (p *Policy) For(components []*jira.Component, extra []string) []string {
+	seen := make(map[string]bool)
+	results := []string{}
+	add := func(users []string) {
+		for _, user := range users {
+			if !seen[strings.ToLower(user)] {
+				seen[strings.ToLower(user)] = true
+				results = append(results, user)
+			}
+		}
+	}
+	if p != nil {
+		add(p.Watchers)
+		names := []string{}
+		for _, component := range components {
+			names = append(names, component.Name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			for configured, users := range p.Components {
+				if strings.EqualFold(configured, name) {
+					add(users)
+				}
+			}
+		}
+	}
+	add(extra)
+	return results
+}
+
+// Apply adds the watchers for a new ticket and removes the creator,
+// unless the policy keeps the creator or lists them as a watcher.
+// Failures are reported as warnings, since the ticket exists either
+// way.
+func (p *Policy) Apply(backend jirabackend.Backend, issue *jira.Issue, components []*jira.Component, extra []string) {
+	added := []string{}
+	keepCreator := p != nil && p.KeepCreator
+
+	myself, err := backend.Myself()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Could not look up the jira user: %s\n", err)
+		keepCreator = true
+	}
+
+	for _, name := range p.For(components, extra) {
+		user := usermap.ParseUser(name)
+		if myself != nil && usermap.SameUser(user, myself) {
+			keepCreator = true
+			continue
+		}
+		if err := backend.AddWatcher(issue.ID, user); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Could not add %s as a watcher of %s: %s\n",
+				name, issue.Key, err)
+			continue
+		}
+		added = append(added, name)
+	}
+	if len(added) > 0 {
+		fmt.Printf("  WATCHERS %s added %s\n", issue.Key, strings.Join(added, ", "))
+	}
+
+	if keepCreator {
+		return
+	}
+	if err := backend.RemoveWatcher(issue.ID, myself); err != nil {
+		fmt.Fprintf(os.Stderr, "Could not remove watch: %s\n", err)
+	}
+}
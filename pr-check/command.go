@@ -1,10 +1,10 @@
-package main
+// Package prcheck implements the pr-check command, which reports on
+// the pull requests linked from jira tickets.
+package prcheck
 
 import (
 	"context"
-	"flag"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"os"
 	"regexp"
@@ -15,8 +15,9 @@ import (
 	"github.com/andygrunwald/go-jira"
 	"github.com/google/go-github/v32/github"
 	"github.com/pkg/errors"
-	"golang.org/x/oauth2"
-	"gopkg.in/yaml.v2"
+
+	"github.com/openshift-metal3/jira-sync/pkg/jirabackend"
+	syncsettings "github.com/openshift-metal3/jira-sync/pkg/settings"
 )
 
 var pullRequestURLPattern *regexp.Regexp
@@ -25,45 +26,16 @@ func init() {
 	pullRequestURLPattern = regexp.MustCompile("https://github.com/(?P<org>[^/]+)/(?P<repo>[^/]+)/pull/(?P<id>\\d+)")
 }
 
-type jiraSettings struct {
-	User     string `yaml:"user"`
-	Password string `yaml:"password"`
-	URL      string `yaml:"url"`
-}
-
-type githubSettings struct {
-	Token string `yaml:"token"`
-}
-
 type appSettings struct {
-	Jira            jiraSettings   `yaml:"jira"`
-	Github          githubSettings `yaml:"github"`
-	DownstreamOrg   string         `yaml:"downstreamOrg"`
+	Jira            syncsettings.Jira `yaml:"jira"`
+	DownstreamOrg   string            `yaml:"downstreamOrg"`
 	includeObsolete bool
 	verbose         bool
-}
-
-func (settings *appSettings) JiraClient() (*jira.Client, error) {
-	tp := jira.BasicAuthTransport{
-		Username: settings.Jira.User,
-		Password: settings.Jira.Password,
-	}
-
-	jiraClient, err := jira.NewClient(tp.Client(), settings.Jira.URL)
-	if err != nil {
-		return nil, errors.Wrap(err, "could not create jira client")
-	}
-	return jiraClient, nil
-}
 
-func (settings *appSettings) GithubClient() (*github.Client, error) {
-	ctx := context.Background()
-	tokenSource := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: settings.Github.Token},
-	)
-	oauthClient := oauth2.NewClient(ctx, tokenSource)
-	githubClient := github.NewClient(oauthClient)
-	return githubClient, nil
+	// jiraBackend and githubClient are shared by all of the workers,
+	// so that they use one login and one transport.
+	jiraBackend  jirabackend.Backend
+	githubClient *github.Client
 }
 
 type repoPRCache struct {
@@ -115,10 +87,7 @@ func (c *cache) getDetails(settings *appSettings, org, repo string) (*repoPRCach
 	repoKey := fmt.Sprintf("%s/%s", org, repo)
 	prCache, ok := c.pullRequestsByRepo[repoKey]
 
-	ghClient, err := settings.GithubClient()
-	if err != nil {
-		return nil, errors.Wrap(err, "could not create github client")
-	}
+	ghClient := settings.githubClient
 
 	if !ok {
 		prCache = repoPRCache{
@@ -162,40 +131,6 @@ func (c *cache) getDetails(settings *appSettings, org, repo string) (*repoPRCach
 	return &prCache, nil
 }
 
-func loadSettings(filename string) (*appSettings, error) {
-
-	content, err := ioutil.ReadFile(filename)
-	if err != nil {
-		return nil, err
-	}
-
-	result := appSettings{}
-	err = yaml.Unmarshal(content, &result)
-	if err != nil {
-		return nil, err
-	}
-
-	if result.Jira.URL == "" {
-		return nil, fmt.Errorf("No jira.url found in %s", filename)
-	}
-	if result.Jira.User == "" {
-		return nil, fmt.Errorf("No jira.user found in %s", filename)
-	}
-	if result.Jira.Password == "" {
-		return nil, fmt.Errorf("No jira.password found in %s", filename)
-	}
-
-	if result.Github.Token == "" {
-		return nil, fmt.Errorf("No github.token found in %s", filename)
-	}
-
-	if result.DownstreamOrg == "" {
-		return nil, fmt.Errorf("No downstreamOrg found in %s", filename)
-	}
-
-	return &result, nil
-}
-
 func issueTitleLine(issue *jira.Issue, jiraURL string) string {
 	return fmt.Sprintf("%s (%s) %s/browse/%s %q",
 		issue.Fields.Type.Name,
@@ -286,10 +221,7 @@ func processOneLink(settings *appSettings, cache *cache, url string) (*linkResul
 		fmt.Fprintf(os.Stderr, "getting details for %s\n", url)
 	}
 
-	ghClient, err := settings.GithubClient()
-	if err != nil {
-		return nil, errors.Wrap(err, "could not create github client")
-	}
+	ghClient := settings.githubClient
 
 	result := &linkResult{
 		url: url,
@@ -474,20 +406,16 @@ func processOneIssue(settings *appSettings, cache *cache, issueID string) (*issu
 		fmt.Fprintf(os.Stderr, "getting details for %s\n", issueID)
 	}
 
-	jiraClient, err := settings.JiraClient()
-	if err != nil {
-		return nil, errors.Wrap(err, "could not create jira client")
-	}
-
+	jiraBackend := settings.jiraBackend
 	result := &issueResult{}
 
-	issue, _, err := jiraClient.Issue.Get(issueID, nil)
+	issue, err := jiraBackend.GetIssue(issueID, nil)
 	if err != nil {
 		return nil, errors.Wrap(err, fmt.Sprintf("error processing issue %q", issueID))
 	}
 	result.issue = issue
 
-	links, err := getLinks(issue, jiraClient)
+	links, err := getLinks(issue, jiraBackend.Client())
 	if err != nil {
 		return nil, errors.Wrap(err, fmt.Sprintf("could not get links for %q", issueID))
 	}
@@ -510,7 +438,7 @@ func processOneIssue(settings *appSettings, cache *cache, issueID string) (*issu
 			searchTerm = "Parent Link"
 		}
 		search := fmt.Sprintf("\"%s\" = %s", searchTerm, issueID)
-		children, _, err := jiraClient.Issue.Search(search, &searchOptions)
+		children, err := jiraBackend.Search(search, &searchOptions)
 		if err != nil {
 			return nil, errors.Wrap(err,
 				fmt.Sprintf("could not find sub-issues related to %s", issueID))
@@ -595,66 +523,67 @@ func processIssues(settings *appSettings, cache *cache, ids []string) []*issueRe
 	return results
 }
 
-func main() {
-	token := flag.String("github-token", "", "the API token")
-	username := flag.String("jira-user", "", "the username")
-	password := flag.String("jira-password", "", "the password")
-	downstreamOrg := flag.String("downstream-org", "openshift",
+// Run runs the command with the arguments following its name and
+// returns the exit code.
+func Run(cmdArgs []string) int {
+	flags := syncsettings.NewFlagSet("pr-check", "issue-key...",
+		"Show the status of the pull requests linked from the jira tickets,\n"+
+			"and of their backports to the downstream org.")
+	server := syncsettings.NewFlags(flags)
+	server.AddJira(flags)
+	server.AddGithub(flags)
+	downstreamOrg := flags.String("downstream-org", "openshift",
 		"the downstream github organization")
-	jiraURL := flag.String("jira-url", "", "the jira server URL")
-	includeObsolete := flag.Bool("include-obsolete", false, "include obsolete tickets")
-	verbose := flag.Bool("v", false, "verbose mode")
+	includeObsolete := flags.Bool("include-obsolete", false, "include obsolete tickets")
+	verbose := flags.Bool("v", false, "verbose mode")
 
-	flag.Parse()
+	if err := flags.Parse(cmdArgs); err != nil {
+		return syncsettings.ParseExit(err)
+	}
 
-	if *token == "" {
-		fmt.Fprintf(os.Stderr, "Please provide an API token (-bugzilla-token)")
-		os.Exit(1)
+	if err := server.Resolve(nil); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
 	}
 
-	if *username == "" || *password == "" {
-		fmt.Fprintf(os.Stderr, "Please specify both username (-jira-user) and password (-jira-password)")
-		os.Exit(1)
+	if err := server.RequireGithub(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
 	}
 
-	if *jiraURL == "" {
-		fmt.Fprintf(os.Stderr, "Please specify the -jira-url")
-		os.Exit(1)
+	if err := server.RequireJira(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
 	}
 
 	settings := &appSettings{
-		Jira: jiraSettings{
-			User:     *username,
-			Password: *password,
-			URL:      *jiraURL,
-		},
-		Github: githubSettings{
-			Token: *token,
-		},
+		Jira:            server.Jira,
 		DownstreamOrg:   *downstreamOrg,
 		verbose:         *verbose,
 		includeObsolete: *includeObsolete,
 	}
 
-	_, err := settings.JiraClient()
+	var err error
+	settings.jiraBackend, err = server.JiraBackend()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Could not create jira client: %v\n", err)
-		os.Exit(1)
+		return 1
 	}
 
-	_, err = settings.GithubClient()
+	tc, err := server.GithubHTTPClient()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Could not create githubclient: %v\n", err)
-		os.Exit(1)
+		fmt.Fprintf(os.Stderr, "Could not create github client: %v\n", err)
+		return 1
 	}
+	settings.githubClient = github.NewClient(tc)
 
 	cache := &cache{
 		pullRequestsByRepo: make(map[string]repoPRCache),
 	}
 
-	results := processIssues(settings, cache, flag.Args())
+	results := processIssues(settings, cache, flags.Args())
 	for _, result := range results {
 		showOneIssueResult(settings, result, "")
 	}
-
+	return 0
 }
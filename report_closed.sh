@@ -16,10 +16,6 @@ if [ -z "$jira_url" ]; then
 fi
 
 header "Reporting on items closed upstream but not in jira"
-go run ./find-closed/main.go \
-    -jira-user "$jira_user" \
-    -jira-password "$jira_password" \
-    -jira-url "$jira_url" \
-    -bugzilla-url "$bugzilla_url" \
-    -github-token "$github_token" \
+go run ./jira-sync find-closed \
+    -settings "$SETTINGS_FILE" \
     -jira-project KNIDEPLOY
@@ -26,11 +26,10 @@ function header {
 # header "Removing old logs"
 # find $LOGDIR -ctime 7 -print -exec rm '{}' \;
 
-github_to_jira=$BINDIR/github-to-jira
-bugzilla_to_jira=$BINDIR/bugzilla-to-jira
-find_closed=$BINDIR/find-closed
+jira_sync=$BINDIR/jira-sync
 
 SETTINGS_FILE=${SETTINGS_FILE:-/etc/jira-sync/settings.sh}
+JOBS_FILE=${JOBS_FILE:-${BINDIR}/jobs.yaml}
 
 source ${SETTINGS_FILE}
 
@@ -39,124 +38,13 @@ if [ -z "$jira_url" ]; then
     exit 1
 fi
 
-header "Importing all items from openshift forks of metal3 repos for the hardware team"
-$github_to_jira \
-    -jira-user "$jira_user" \
-    -jira-password "$jira_password" \
-    -jira-url "$jira_url" \
-    -github-token "$github_token" \
-    -jira-project KNIDEPLOY \
-    -jira-component 'KNI Deploy HW Mgmt' \
-    \
-    -github-org openshift \
-    ironic \
-    ironic-hardware-inventory-recorder-image \
-    ironic-image \
-    ironic-inspector \
-    ironic-inspector-image \
-    ironic-ipa-downloader \
-    ironic-lib \
-    ironic-prometheus-exporter \
-    ironic-rhcos-downloader \
-    ironic-static-ip-manager \
-    metal3-smart-exporter
-
-header "Importing all items from openshift forks of metal3 repos for installer team"
-$github_to_jira \
-    -jira-user "$jira_user" \
-    -jira-password "$jira_password" \
-    -jira-url "$jira_url" \
-    -github-token "$github_token" \
-    -jira-project KNIDEPLOY \
-    -jira-component 'KNI Deploy Install' \
-    \
-    -github-org openshift \
-    baremetal-operator \
-    cluster-api-provider-baremetal
-
-header "Importing openshift items tagged platform/baremetal"
-$github_to_jira \
-    -jira-user "$jira_user" \
-    -jira-password "$jira_password" \
-    -jira-url "$jira_url" \
-    -github-token "$github_token" \
-    -jira-project KNIDEPLOY \
-    -jira-component 'KNI Deploy Install' \
-    \
-    -github-org openshift \
-    -github-label 'platform/baremetal'
-
-header "Importing metal3-io items for the hardware team"
-$github_to_jira \
-    -jira-user "$jira_user" \
-    -jira-password "$jira_password" \
-    -jira-url "$jira_url" \
-    -github-token "$github_token" \
-    -jira-project KNIDEPLOY \
-    -jira-component 'KNI Deploy HW Mgmt' \
-    \
-    -github-org metal3-io \
-    ironic \
-    ironic-hardware-inventory-recorder-image \
-    ironic-image \
-    ironic-inspector-image \
-    ironic-ipa-downloader \
-    ironic-prometheus-exporter \
-    metal3-smart-exporter
-
-header "Importing metal3-io items"
-$github_to_jira \
-    -jira-user "$jira_user" \
-    -jira-password "$jira_password" \
-    -jira-url "$jira_url" \
-    -github-token "$github_token" \
-    -jira-project KNIDEPLOY \
-    -jira-component 'KNI Deploy Install' \
-    \
-    -github-org metal3-io \
-    -github-ignore metal3-io.github.io,cluster-api-provider-metal3,hardware-classification-controller,metal3-helm-chart
-
-header "Importing openshift-metal3 items for the UX team"
-$github_to_jira \
-    -jira-user "$jira_user" \
-    -jira-password "$jira_password" \
-    -jira-url "$jira_url" \
-    -github-token "$github_token" \
-    -jira-project KNIDEPLOY \
-    -jira-component 'KNI Deploy UI & Validations' \
-    \
-    -github-org openshift-metal3 \
-    facet
-
-header "Importing openshift-metal3 items"
-$github_to_jira \
-    -jira-user "$jira_user" \
-    -jira-password "$jira_password" \
-    -jira-url "$jira_url" \
-    -github-token "$github_token" \
-    -jira-project KNIDEPLOY \
-    -jira-component 'KNI Deploy Install' \
-    \
-    -github-org openshift-metal3
-
-# header "Importing bugzilla 'KNI Deploy Install' items"
-# $bugzilla_to_jira \
-#     -jira-user "$jira_user" \
-#     -jira-password "$jira_password" \
-#     -jira-url "$jira_url" \
-#     -bugzilla-token "$bugzilla_token" \
-#     -bugzilla-url "$bugzilla_url" \
-#     -jira-project KNIDEPLOY \
-#     -jira-component 'KNI Deploy Install' \
-#     \
-#     -bugzilla-product 'Kubernetes-native Infrastructure' \
-#     -bugzilla-component 'Deployment'
+# Each job prints its own header, and a summary of all of them at the
+# end.
+$jira_sync run \
+    -settings "$SETTINGS_FILE" \
+    -config "$JOBS_FILE"
 
 header "Reporting on items closed upstream but not in jira"
-$find_closed \
-    -jira-user "$jira_user" \
-    -jira-password "$jira_password" \
-    -jira-url "$jira_url" \
-    -bugzilla-url "$bugzilla_url" \
-    -github-token "$github_token" \
+$jira_sync find-closed \
+    -settings "$SETTINGS_FILE" \
     -jira-project KNIDEPLOY